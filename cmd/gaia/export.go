@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/go-wire/data"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tendermint/tmlibs/cli"
+
+	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/app"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/stack"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/gov"
+	"github.com/cosmos/gaia/modules/stake"
+	"github.com/cosmos/gaia/version"
+)
+
+// nolint
+const (
+	FlagHeight  = "height"
+	FlagChainID = "chain-id"
+)
+
+// ExportCmd - write the committed state as the genesis of a new chain
+var ExportCmd = &cobra.Command{
+	Use:   "export [genesis-file]",
+	Short: "Export the accounts, the staking and the governance state as a genesis file, printed if no file is given",
+	RunE:  exportCmd,
+}
+
+func init() {
+	ExportCmd.Flags().Int64(FlagHeight, 0, "Height to export, the latest committed height by default."+
+		" Stop the node at the height to export it")
+	ExportCmd.Flags().String(FlagChainID, "", "Chain id of the new chain, the chain id of the exported chain by default")
+}
+
+// genesisAccount - an account of the coin module as read from the genesis
+type genesisAccount struct {
+	Address data.Bytes `json:"address"`
+	Coins   coin.Coins `json:"coins"`
+}
+
+// appOptions - the app_options of the genesis
+type appOptions struct {
+	Accounts      []genesisAccount `json:"accounts"`
+	PluginOptions []interface{}    `json:"plugin_options"`
+}
+
+func exportCmd(cmd *cobra.Command, args []string) error {
+	rootDir := viper.GetString(cli.HomeFlag)
+
+	appName := fmt.Sprintf("%s v%v", cmd.Root().Name(), version.Version)
+	storeApp, err := app.NewStoreApp(
+		appName,
+		path.Join(rootDir, "data", "merkleeyes.db"),
+		eyesCacheSize,
+		logger.With("module", "app"))
+	if err != nil {
+		return err
+	}
+
+	// only the latest version of the store can be read as a whole
+	height := storeApp.CommittedHeight()
+	if h := viper.GetInt64(FlagHeight); h != 0 && uint64(h) != height {
+		return errors.Errorf("the store is committed at height %d, stop the node at"+
+			" height %d to export it", height, h)
+	}
+
+	chainID := viper.GetString(FlagChainID)
+	if len(chainID) == 0 {
+		chainID = storeApp.GetChainID()
+	}
+	genDoc, err := exportGenesis(storeApp.Committed(), chainID, height)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		bz, err := json.MarshalIndent(genDoc, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(bz))
+		return nil
+	}
+	logger.Info("Exported the state", "height", height, "file", args[0])
+	return genDoc.SaveAs(args[0])
+}
+
+// build the genesis restoring the accounts, the staking and the governance
+// state of the store, committed at height. The heights are moved back by it, so
+// the new chain starts where the exported one stopped. The store must hold no
+// accounts which can't be restored.
+func exportGenesis(store state.SimpleDB, chainID string, height uint64) (*types.GenesisDoc, error) {
+	stakeStore := stack.PrefixedStore(stake.Name(), store)
+	govStore := stack.PrefixedStore(gov.Name(), store)
+
+	options := appOptions{}
+	var holdCoins []stake.GenesisCoins
+	var depositCoins coin.Coins
+	prefix := stack.PrefixedKey(coin.NameCoin, nil)
+	for _, model := range store.List(prefix, prefixEnd(prefix), 0) {
+		var actor sdk.Actor
+		var account coin.Account
+		if wire.ReadBinaryBytes(model.Key[len(prefix):], &actor) != nil ||
+			wire.ReadBinaryBytes(model.Value, &account) != nil {
+			continue // not an account
+		}
+		if account.Coins.IsZero() {
+			continue
+		}
+
+		switch {
+		case actor.ChainID == "" && actor.App == auth.NameSigs:
+			options.Accounts = append(options.Accounts, genesisAccount{actor.Address, account.Coins})
+		case actor.ChainID == "" && actor.App == stake.Name():
+			holdCoins = append(holdCoins, stake.GenesisCoins{actor, account.Coins})
+		case actor.Equals(gov.DepositAccount):
+			depositCoins = account.Coins
+		default:
+			return nil, errors.Errorf("cannot export the account %v/%v/%X with %v",
+				actor.ChainID, actor.App, actor.Address, account.Coins)
+		}
+	}
+
+	stakeGenesis := stake.ExportGenesis(stakeStore, holdCoins, height)
+	govGenesis := gov.ExportGenesis(govStore, depositCoins, height)
+	options.PluginOptions = []interface{}{
+		"stake/genesis", stakeGenesis,
+		"gov/genesis", govGenesis,
+	}
+
+	// tendermint starts with the validator set it ran with on the exported
+	// chain, which lags the voting power of the bonds within an epoch
+	genDoc := &types.GenesisDoc{
+		GenesisTime: time.Now(),
+		ChainID:     chainID,
+		AppOptions:  options,
+	}
+	monikers := make(map[string]string)
+	for _, bond := range stakeGenesis.Bonds {
+		monikers[string(bond.PubKey)] = bond.Description.Moniker
+	}
+	for _, val := range stakeGenesis.ValidatorSet {
+		pubKey, err := crypto.PubKeyFromBytes(val.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		genDoc.Validators = append(genDoc.Validators, types.GenesisValidator{
+			PubKey: pubKey,
+			Power:  int64(val.Power),
+			Name:   monikers[string(val.PubKey)],
+		})
+	}
+	return genDoc, nil
+}
+
+// the end of the range of the keys starting with the prefix
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/tendermint/types"
+
+	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/stack"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/gov"
+	"github.com/cosmos/gaia/modules/stake"
+	"github.com/cosmos/gaia/modules/stake/invariants"
+)
+
+// add a genesis validator of the address bonding amount, and give the address
+// an account of balance coins
+func addTestValidator(t *testing.T, store state.SimpleDB, address []byte,
+	amount, balance int64) crypto.PubKey {
+
+	stakeStore := stack.PrefixedStore(stake.Name(), store)
+	coinStore := stack.PrefixedStore(coin.NameCoin, store)
+	pubKey := crypto.GenPrivKeyEd25519().PubKey()
+	value := fmt.Sprintf(`{"address": "%X", "pub_key": {"type": "ed25519", "data": "%X"},`+
+		` "amount": {"denom": "fermion", "amount": %d}, "description": {"moniker": "val"}}`,
+		address, pubKey.Bytes(), amount)
+	_, err := stake.NewHandler().InitState(logger, stakeStore, stake.Name(), "validator", value, nil)
+	require.Nil(t, err)
+	require.Nil(t, stake.ProcessGenesisCoins(stakeStore, coinStore))
+	_, err = coin.ChangeCoins(coinStore, auth.SigPerm(address), coin.Coins{{"fermion", balance}})
+	require.Nil(t, err)
+	return pubKey
+}
+
+// set the genesis in a new store as the node would, up to the first block
+// crediting the coins held by the modules
+func importGenesis(t *testing.T, genDoc *types.GenesisDoc) state.SimpleDB {
+	store := state.NewMemKVStore()
+	stakeStore := stack.PrefixedStore(stake.Name(), store)
+	govStore := stack.PrefixedStore(gov.Name(), store)
+	coinStore := stack.PrefixedStore(coin.NameCoin, store)
+
+	options := genDoc.AppOptions.(appOptions)
+	for _, acc := range options.Accounts {
+		_, err := coin.ChangeCoins(coinStore, auth.SigPerm(acc.Address), acc.Coins)
+		require.Nil(t, err)
+	}
+	for i := 0; i < len(options.PluginOptions); i += 2 {
+		bz, err := json.Marshal(options.PluginOptions[i+1])
+		require.Nil(t, err)
+		switch options.PluginOptions[i] {
+		case "stake/genesis":
+			_, err = stake.NewHandler().InitState(logger, stakeStore, stake.Name(), "genesis", string(bz), nil)
+		case "gov/genesis":
+			_, err = gov.NewHandler().InitState(logger, govStore, gov.Name(), "genesis", string(bz), nil)
+		}
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, stake.ProcessGenesisCoins(stakeStore, coinStore))
+	require.Nil(t, gov.ProcessGenesisCoins(govStore, coinStore))
+	return store
+}
+
+// the coins of the denom held by all the accounts of the store
+func totalCoins(store state.SimpleDB, denom string) (total int64) {
+	prefix := stack.PrefixedKey(coin.NameCoin, nil)
+	for _, model := range store.List(prefix, prefixEnd(prefix), 0) {
+		var account coin.Account
+		if wire.ReadBinaryBytes(model.Value, &account) != nil {
+			continue
+		}
+		for _, c := range account.Coins {
+			if c.Denom == denom {
+				total += c.Amount
+			}
+		}
+	}
+	return
+}
+
+// delivers the coin txs dispatched by the handlers within the coin store
+type coinDispatch struct {
+	coinStore state.SimpleDB
+}
+
+func (d coinDispatch) DeliverTx(ctx sdk.Context, _ state.SimpleDB,
+	tx sdk.Tx) (sdk.DeliverResult, error) {
+
+	return coin.NewHandler().DeliverTx(ctx, d.coinStore, tx, nil)
+}
+
+func TestExportGenesis(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	coinStore := stack.PrefixedStore(coin.NameCoin, store)
+
+	// a genesis validator, an account and the deposits of the gov module
+	pubKey := addTestValidator(t, store, []byte{1, 2}, 100, 900)
+	_, err := coin.ChangeCoins(coinStore, gov.DepositAccount, coin.Coins{{"fermion", 50}})
+	require.Nil(err)
+
+	genDoc, err := exportGenesis(store, "new-chain", 0)
+	require.Nil(err)
+	assert.Equal("new-chain", genDoc.ChainID)
+	require.Equal(1, len(genDoc.Validators))
+	assert.Equal(pubKey, genDoc.Validators[0].PubKey)
+	assert.Equal(int64(100), genDoc.Validators[0].Power)
+	assert.Equal("val", genDoc.Validators[0].Name)
+
+	// only the accounts of the signers are exported as such, the hold account
+	// goes with the stake genesis and the deposit account with the gov genesis
+	options := genDoc.AppOptions.(appOptions)
+	require.Equal(1, len(options.Accounts))
+	assert.Equal(coin.Coins{{"fermion", 900}}, options.Accounts[0].Coins)
+	stakeGenesis := options.PluginOptions[1].(stake.Genesis)
+	require.Equal(1, len(stakeGenesis.HoldCoins))
+	assert.Equal(coin.Coins{{"fermion", 100}}, stakeGenesis.HoldCoins[0].Coins)
+	govGenesis := options.PluginOptions[3].(gov.Genesis)
+	assert.Equal(coin.Coins{{"fermion", 50}}, govGenesis.DepositCoins)
+
+	// the new chain starts from a valid genesis
+	bz, err := json.Marshal(genDoc)
+	require.Nil(err)
+	assert.Nil(validateGenesis(bz), "%s", bz)
+
+	// tendermint starts with the set it ran with, even when it lags the
+	// voting power of the bonds within an epoch
+	stakeStore := stack.PrefixedStore(stake.Name(), store)
+	lagging := []*abci.Validator{{PubKey: wire.BinaryBytes(pubKey), Power: 60}}
+	stakeStore.Set(stake.ValidatorSetKey, wire.BinaryBytes(lagging))
+	genDoc, err = exportGenesis(store, "new-chain", 0)
+	require.Nil(err)
+	require.Equal(1, len(genDoc.Validators))
+	assert.Equal(int64(60), genDoc.Validators[0].Power)
+	assert.Equal("val", genDoc.Validators[0].Name)
+	newStakeStore := stack.PrefixedStore(stake.Name(), importGenesis(t, genDoc))
+	assert.Equal(stakeStore.Get(stake.ValidatorSetKey), newStakeStore.Get(stake.ValidatorSetKey))
+
+	// the accounts of other modules can't be restored, the export fails
+	_, err = coin.ChangeCoins(coinStore, sdk.NewActor("roles", []byte("admins")), coin.Coins{{"fermion", 5}})
+	require.Nil(err)
+	_, err = exportGenesis(store, "new-chain", 0)
+	assert.NotNil(err)
+}
+
+func TestExportGenesisRoundTrip(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	stakeStore := stack.PrefixedStore(stake.Name(), store)
+	govStore := stack.PrefixedStore(gov.Name(), store)
+	coinStore := stack.PrefixedStore(coin.NameCoin, store)
+	address := []byte{1, 2}
+	sender := auth.SigPerm(address)
+	pubKey := addTestValidator(t, store, address, 100, 900)
+
+	// an unbonding and a proposal being voted on, at height 10
+	ctx := stack.MockContext("old-chain", 10).WithPermissions(sender)
+	dispatch := coinDispatch{coinStore}
+	txUnbond := stake.TxUnbondDelegation{coin.Coin{"fermion", 20}, wire.BinaryBytes(pubKey)}.Wrap()
+	_, err := stake.NewHandler().DeliverTx(ctx, stakeStore, txUnbond, dispatch)
+	require.Nil(err)
+	txSubmit := gov.NewTxSubmitProposal("title", "description", nil, coin.Coin{"fermion", 100})
+	_, err = gov.NewHandler().DeliverTx(ctx, govStore, txSubmit, dispatch)
+	require.Nil(err)
+	_, err = gov.NewHandler().DeliverTx(ctx, govStore, gov.TxVote{1, gov.OptionYes}.Wrap(), dispatch)
+	require.Nil(err)
+	stake.UpdateValidatorSet(stakeStore, 10)
+	require.Nil(invariants.Check(store))
+
+	exported := stake.ExportGenesis(stakeStore, nil, 0)
+	require.Equal(1, len(exported.UnbondingQueue))
+	release := exported.UnbondingQueue[0].HeightRelease
+	proposal := gov.ExportGenesis(govStore, nil, 0).Proposals[0]
+
+	// exported at height 12, the periods end as many blocks into the new chain
+	genDoc, err := exportGenesis(store, "new-chain", 12)
+	require.Nil(err)
+	options := genDoc.AppOptions.(appOptions)
+	stakeGenesis := options.PluginOptions[1].(stake.Genesis)
+	assert.Equal(release-12, stakeGenesis.UnbondingQueue[0].HeightRelease)
+	govGenesis := options.PluginOptions[3].(gov.Genesis)
+	require.Equal(1, len(govGenesis.Proposals))
+	assert.Equal(proposal.VotingEndHeight-12, govGenesis.Proposals[0].VotingEndHeight)
+	assert.Equal(1, len(govGenesis.Deposits))
+	assert.Equal(1, len(govGenesis.Votes))
+
+	// the new chain holds the same supply
+	newStore := importGenesis(t, genDoc)
+	newStakeStore := stack.PrefixedStore(stake.Name(), newStore)
+	newGovStore := stack.PrefixedStore(gov.Name(), newStore)
+	newCoinStore := stack.PrefixedStore(coin.NameCoin, newStore)
+	assert.Equal(totalCoins(store, "fermion"), totalCoins(newStore, "fermion"))
+	require.Nil(invariants.Check(newStore))
+
+	// the coins are released and the proposal ends at the shifted heights
+	balance := func() int64 {
+		account, err := coin.GetAccount(newCoinStore, sender)
+		require.Nil(err)
+		return account.Coins[0].Amount
+	}
+	assert.Equal(int64(800), balance())
+	require.Nil(stake.ProcessUnbondingQueue(newStakeStore, newCoinStore, release-13))
+	assert.Equal(int64(800), balance())
+	require.Nil(stake.ProcessUnbondingQueue(newStakeStore, newCoinStore, release-12))
+	assert.Equal(int64(820), balance())
+
+	end := proposal.VotingEndHeight - 12
+	require.Nil(gov.ProcessProposals(newGovStore, newStakeStore, newCoinStore, end))
+	assert.Equal(int64(920), balance()) // the deposit is returned
+	assert.Equal(totalCoins(store, "fermion"), totalCoins(newStore, "fermion"))
+	require.Nil(invariants.Check(newStore))
+}
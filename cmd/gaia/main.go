@@ -20,7 +20,9 @@ import (
 	"github.com/cosmos/cosmos-sdk/stack"
 	"github.com/cosmos/cosmos-sdk/state"
 
+	"github.com/cosmos/gaia/modules/gov"
 	"github.com/cosmos/gaia/modules/stake"
+	"github.com/cosmos/gaia/modules/stake/invariants"
 	"github.com/cosmos/gaia/version"
 )
 
@@ -34,18 +36,80 @@ var RootCmd = &cobra.Command{
 // process all queues, validator rewards, and calculate the validator set difference
 func tickFn(ctx sdk.Context, store state.SimpleDB) (diffVal []*abci.Validator, err error) {
 	// First need to prefix the store, at this point it's a global store
+	appStore := store
+	coinStore := stack.PrefixedStore(coin.NameCoin, store)
+	govStore := stack.PrefixedStore(gov.Name(), store)
 	store = stack.PrefixedStore(stake.Name(), store)
 
-	// Determine the validator set changes
-	validatorBonds := stake.LoadBonds(store)
-	startVal := validatorBonds.GetValidators(store)
-	changed := validatorBonds.UpdateVotingPower(store)
-	if !changed {
+	// Move the validator bonds of older chains to their own keys
+	stake.MigrateBonds(store)
+
+	// Credit the coins bonded by the genesis validators to their hold accounts
+	err = stake.ProcessGenesisCoins(store, coinStore)
+	if err != nil {
+		return
+	}
+
+	// Credit the coins of the proposal deposits restored by the genesis
+	err = gov.ProcessGenesisCoins(govStore, coinStore)
+	if err != nil {
+		return
+	}
+
+	// Move the coins of the validators to the hold accounts derived from their sender
+	err = stake.MigrateHoldAccounts(store, coinStore)
+	if err != nil {
 		return
 	}
-	newVal := validatorBonds.GetValidators(store)
-	diffVal = stake.ValidatorsDiff(startVal, newVal, store)
-	validatorBonds.CleanupEmpty(store)
+
+	// Slash and revoke the validators reported for double signing
+	err = stake.ProcessByzantineValidators(store, coinStore, beginBlock.ByzantineValidators)
+	if err != nil {
+		return
+	}
+
+	// Jail the validators which have been absent for too many blocks
+	err = stake.ProcessAbsentValidators(store, coinStore, ctx.BlockHeight(), beginBlock.AbsentValidators)
+	if err != nil {
+		return
+	}
+
+	// Return the unbonded coins which have waited out the unbonding period
+	err = stake.ProcessUnbondingQueue(store, coinStore, ctx.BlockHeight())
+	if err != nil {
+		return
+	}
+
+	// Forget the redelegations which can no longer be slashed
+	stake.ProcessRedelegationQueue(store, ctx.BlockHeight())
+
+	// Mint the block provisions as rewards for the bonded validators
+	err = stake.ProcessProvisions(store, coinStore)
+	if err != nil {
+		return
+	}
+
+	// End the voting on proposals, applying the param changes which passed
+	err = gov.ProcessProposals(govStore, store, coinStore, ctx.BlockHeight())
+	if err != nil {
+		return
+	}
+
+	// Determine the validator set changes, applied at the end of each epoch
+	diffVal = stake.UpdateValidatorSet(store, ctx.BlockHeight())
+
+	// Remove the validators left without bond tokens, even those which had no
+	// voting power to lose
+	stake.LoadBonds(store).CleanupEmpty(store)
+
+	// Halt rather than commit a state breaking the staking invariants
+	if checkInvariants {
+		err = invariants.Check(appStore)
+		if err != nil {
+			logger.Error("Staking invariant broken, halting", "height", ctx.BlockHeight(), "err", err)
+			os.Exit(1)
+		}
+	}
 	return
 }
 
@@ -70,11 +134,14 @@ func main() {
 			stack.WrapHandler(roles.NewHandler()),
 			stack.WrapHandler(ibc.NewHandler()),
 			stake.NewHandler(),
+			gov.NewHandler(),
 		)
 
 	RootCmd.AddCommand(
 		basecmd.GetInitCmd("fermion", []string{"stake/allowed_bond_denom/fermion"}),
-		basecmd.GetTickStartCmd(sdk.TickerFunc(tickFn)),
+		GetStartCmd(sdk.TickerFunc(tickFn)),
+		ValidateGenesisCmd,
+		ExportCmd,
 		basecmd.UnsafeResetAllCmd,
 		version.VersionCmd,
 	)
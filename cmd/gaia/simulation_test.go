@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+
+	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/stack"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/stake"
+	"github.com/cosmos/gaia/modules/stake/invariants"
+)
+
+// the simulation is random unless a seed is given, the seed of a failed run
+// is printed so it can be replayed:
+//
+//	go test ./cmd/gaia -run TestSimulation -sim.seed=<seed>
+var (
+	simSeed   = flag.Int64("sim.seed", 0, "seed of the stake simulation, random if 0")
+	simBlocks = flag.Int("sim.blocks", 100, "number of blocks of the stake simulation")
+	simTxs    = flag.Int("sim.txs", 20, "number of transactions per block of the stake simulation")
+)
+
+const (
+	simChainID   = "sim-chain"
+	simActors    = 20
+	simBalance   = 10000 // coins of each actor at genesis
+	simMaxVals   = 5     // fewer than the candidates, so the validator set changes
+	simUnbonding = 10    // blocks
+)
+
+// simDispatcher - delivers the transactions to the coin and stake handlers,
+// each with the store of its module, as the dispatcher of the app does
+type simDispatcher struct {
+	store state.SimpleDB // the store of the app
+}
+
+func (d simDispatcher) DeliverTx(ctx sdk.Context, _ state.SimpleDB,
+	tx sdk.Tx) (sdk.DeliverResult, error) {
+
+	if _, ok := tx.Unwrap().(coin.SendTx); ok {
+		return coin.NewHandler().DeliverTx(ctx, stack.PrefixedStore(coin.NameCoin, d.store), tx, d)
+	}
+	return stake.NewHandler().DeliverTx(ctx, stack.PrefixedStore(stake.Name(), d.store), tx, d)
+}
+
+// simulation - the state of the chain and what the actors have done to it
+type simulation struct {
+	r        *rand.Rand
+	store    state.SimpleDB
+	height   uint64
+	actors   []sdk.Actor
+	privKeys []crypto.PrivKey // the validator key of each actor
+	pubKeys  [][]byte
+}
+
+func newSimulation(t *testing.T, seed int64) *simulation {
+	require := require.New(t)
+
+	s := &simulation{
+		r:     rand.New(rand.NewSource(seed)),
+		store: state.NewMemKVStore(),
+	}
+
+	// the supply is the coins of the actors
+	value := fmt.Sprintf(`{"params": {"max_vals": %d, "unbonding_period": %d, "total_supply": %d}}`,
+		simMaxVals, simUnbonding, simActors*simBalance)
+	_, err := stake.NewHandler().InitState(logger, stack.PrefixedStore(stake.Name(), s.store),
+		stake.Name(), "genesis", value, nil)
+	require.Nil(err)
+
+	coinStore := stack.PrefixedStore(coin.NameCoin, s.store)
+	for i := 0; i < simActors; i++ {
+		actor := auth.SigPerm([]byte(fmt.Sprintf("simulation-actor-%02d", i)))
+		_, err = coin.ChangeCoins(coinStore, actor, coin.Coins{{"fermion", simBalance}})
+		require.Nil(err)
+		s.actors = append(s.actors, actor)
+		privKey := crypto.GenPrivKeyEd25519FromSecret([]byte(actor.Address)).Wrap()
+		s.privKeys = append(s.privKeys, privKey)
+		s.pubKeys = append(s.pubKeys, wire.BinaryBytes(privKey.PubKey()))
+	}
+	return s
+}
+
+// a random transaction from a random actor, some of them are bound to fail
+func (s *simulation) randomTx() (sdk.Actor, sdk.Tx) {
+	i := s.r.Intn(simActors)
+	amount := coin.Coin{"fermion", 1 + s.r.Int63n(simBalance/5)}
+	pubKey := s.pubKeys[s.r.Intn(simActors)]
+
+	var tx sdk.Tx
+	switch s.r.Intn(5) {
+	case 0:
+		description := stake.NewDescription(fmt.Sprintf("validator-%02d", i), "", "", "")
+		commission := stake.NewCommission(stake.NewFraction(s.r.Int63n(10), 100),
+			stake.NewFraction(10, 100), stake.NewFraction(1, 100))
+		// the actor can only prove the possession of its own pubkey, the
+		// other pubkeys are squatting attempts
+		if s.r.Intn(4) > 0 {
+			pubKey = s.pubKeys[i]
+		}
+		signature := s.privKeys[i].Sign(stake.PossessionSignBytes(simChainID, s.actors[i]))
+		tx = stake.NewTxDeclareCandidacy(amount, pubKey, signature.Bytes(), description, commission)
+	case 1:
+		tx = stake.NewTxBond(amount, nil, nil)
+	case 2:
+		tx = stake.NewTxUnbond(amount)
+	case 3:
+		tx = stake.NewTxDelegate(amount, pubKey)
+	default:
+		tx = stake.NewTxUnbondDelegation(amount, pubKey)
+	}
+	return s.actors[i], tx
+}
+
+// deliver a transaction signed by the sender, its changes are discarded if it
+// fails, as by the checkpoint of the app
+func (s *simulation) deliverTx(sender sdk.Actor, tx sdk.Tx) error {
+	ctx := stack.MockContext(simChainID, s.height).WithPermissions(sender)
+	cache := s.store.Checkpoint()
+	_, err := simDispatcher{cache}.DeliverTx(ctx, cache, tx)
+	if err != nil {
+		cache.Discard()
+		return err
+	}
+	return s.store.Commit(cache)
+}
+
+// run the tick at the beginning of the next block
+func (s *simulation) tick() error {
+	s.height++
+	_, err := tickFn(stack.MockContext(simChainID, s.height), s.store)
+	return err
+}
+
+func TestSimulation(t *testing.T) {
+	seed := *simSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	replay := fmt.Sprintf("replay with -sim.seed=%d", seed)
+
+	s := newSimulation(t, seed)
+	var delivered, failed int
+	for block := 0; block < *simBlocks; block++ {
+		require.Nil(t, s.tick(), "tick at height %d, %s", s.height, replay)
+		require.Nil(t, invariants.Check(s.store), "tick at height %d, %s", s.height, replay)
+
+		for i := 0; i < *simTxs; i++ {
+			sender, tx := s.randomTx()
+			if s.deliverTx(sender, tx) != nil {
+				failed++
+				continue
+			}
+			delivered++
+			require.Nil(t, invariants.CheckAfterTx(s.store), "%T from %X at height %d, %s",
+				tx.Unwrap(), sender.Address, s.height, replay)
+		}
+	}
+
+	// the queued coins are returned once the unbonding period has passed
+	for i := 0; i < simUnbonding; i++ {
+		require.Nil(t, s.tick(), "tick at height %d, %s", s.height, replay)
+		require.Nil(t, invariants.Check(s.store), "tick at height %d, %s", s.height, replay)
+	}
+	t.Logf("seed %d: %d transactions delivered, %d rejected over %d blocks",
+		seed, delivered, failed, s.height)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/tendermint/abci/server"
+	abci "github.com/tendermint/abci/types"
+	tcmd "github.com/tendermint/tendermint/cmd/tendermint/commands"
+	"github.com/tendermint/tendermint/node"
+	"github.com/tendermint/tendermint/proxy"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tendermint/tmlibs/cli"
+	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tmlibs/log"
+
+	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/app"
+	"github.com/cosmos/cosmos-sdk/genesis"
+	basecmd "github.com/cosmos/cosmos-sdk/server/commands"
+
+	"github.com/cosmos/gaia/version"
+)
+
+// nolint
+const (
+	FlagAddress           = "address"
+	FlagWithoutTendermint = "without-tendermint"
+	FlagCheckInvariants   = "check-invariants"
+
+	eyesCacheSize = 10000
+)
+
+var logger = log.NewTMLogger(log.NewSyncWriter(os.Stdout)).With("module", "main")
+
+// beginBlock is the request tendermint sent at the beginning of the current
+// block, it holds the evidence about the validators processed by the tick
+var beginBlock abci.RequestBeginBlock
+
+// checkInvariants is set by --check-invariants, the tick then checks the
+// staking invariants every block
+var checkInvariants bool
+
+// gaiaApp is the basecoin app, extended to record the evidence tendermint
+// reports at the beginning of each block
+type gaiaApp struct {
+	*app.BaseApp
+}
+
+// BeginBlock - ABCI - record the request before the tick is run
+func (a gaiaApp) BeginBlock(req abci.RequestBeginBlock) {
+	beginBlock = req
+	a.BaseApp.BeginBlock(req)
+}
+
+// GetStartCmd - the start command, as provided by the sdk, but for the gaiaApp
+func GetStartCmd(tick sdk.Ticker) *cobra.Command {
+	startCmd := &cobra.Command{
+		Use:   "start",
+		Short: "Start this full node",
+		RunE:  startCmd(tick),
+	}
+
+	flags := startCmd.Flags()
+	flags.String(FlagAddress, "tcp://0.0.0.0:46658", "Listen address")
+	flags.Bool(FlagWithoutTendermint, false, "Only run abci app, assume external tendermint process")
+	flags.Bool(FlagCheckInvariants, false, "Check the staking invariants every block and halt on a violation, for debugging")
+	// add all standard 'tendermint node' flags
+	tcmd.AddNodeFlags(startCmd)
+	return startCmd
+}
+
+func startCmd(tick sdk.Ticker) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		rootDir := viper.GetString(cli.HomeFlag)
+
+		appName := fmt.Sprintf("%s v%v", cmd.Root().Name(), version.Version)
+		storeApp, err := app.NewStoreApp(
+			appName,
+			path.Join(rootDir, "data", "merkleeyes.db"),
+			eyesCacheSize,
+			logger.With("module", "app"))
+		if err != nil {
+			return err
+		}
+		gaia := gaiaApp{app.NewBaseApp(storeApp, basecmd.Handler, tick)}
+		checkInvariants = viper.GetBool(FlagCheckInvariants)
+
+		// if chain_id has not been set yet, load the genesis.
+		// else, assume it's been loaded
+		if gaia.GetChainID() == "" {
+			genesisFile := path.Join(rootDir, "genesis.json")
+			if _, err := os.Stat(genesisFile); err == nil {
+				err = genesis.Load(gaia, genesisFile)
+				if err != nil {
+					return errors.Errorf("Error in LoadGenesis: %v\n", err)
+				}
+			} else {
+				fmt.Printf("No genesis file at %s, skipping...\n", genesisFile)
+			}
+		}
+
+		chainID := gaia.GetChainID()
+		if viper.GetBool(FlagWithoutTendermint) {
+			logger.Info("Starting Gaia without Tendermint", "chain_id", chainID)
+			return startABCI(gaia)
+		}
+		logger.Info("Starting Gaia with Tendermint", "chain_id", chainID)
+		return startTendermint(gaia)
+	}
+}
+
+// run just the abci app/server
+func startABCI(gaia abci.Application) error {
+	addr := viper.GetString(FlagAddress)
+	svr, err := server.NewServer(addr, "socket", gaia)
+	if err != nil {
+		return errors.Errorf("Error creating listener: %v\n", err)
+	}
+	svr.SetLogger(logger.With("module", "abci-server"))
+	svr.Start()
+
+	// Wait forever
+	cmn.TrapSignal(func() {
+		// Cleanup
+		svr.Stop()
+	})
+	return nil
+}
+
+// start the app with tendermint in-process
+func startTendermint(gaia abci.Application) error {
+	cfg, err := tcmd.ParseConfig()
+	if err != nil {
+		return err
+	}
+
+	privValidator := types.LoadOrGenPrivValidatorFS(cfg.PrivValidatorFile())
+	n, err := node.NewNode(cfg,
+		privValidator,
+		proxy.NewLocalClientCreator(gaia),
+		node.DefaultGenesisDocProviderFunc(cfg),
+		node.DefaultDBProvider,
+		logger.With("module", "node"))
+	if err != nil {
+		return err
+	}
+
+	_, err = n.Start()
+	if err != nil {
+		return err
+	}
+
+	// Trap signal, run forever.
+	n.RunForever()
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/tendermint/tmlibs/cli"
+
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/gov"
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+// ValidateGenesisCmd - check the stake and gov options of a genesis file
+// without starting the node
+var ValidateGenesisCmd = &cobra.Command{
+	Use:   "validate-genesis [genesis-file]",
+	Short: "Validate the stake and gov options of the genesis, genesis.json in the home directory by default",
+	RunE:  validateGenesisCmd,
+}
+
+func validateGenesisCmd(cmd *cobra.Command, args []string) error {
+	genesisFile := path.Join(viper.GetString(cli.HomeFlag), "genesis.json")
+	if len(args) == 1 {
+		genesisFile = args[0]
+	}
+
+	bz, err := ioutil.ReadFile(genesisFile)
+	if err != nil {
+		return err
+	}
+	err = validateGenesis(bz)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("The stake and gov options of %s are valid\n", genesisFile)
+	return nil
+}
+
+// the options are set as the node would set them at genesis, in order, each
+// module into a store of its own
+func validateGenesis(bz []byte) error {
+	var doc struct {
+		AppOptions struct {
+			PluginOptions []json.RawMessage `json:"plugin_options"`
+		} `json:"app_options"`
+	}
+	err := json.Unmarshal(bz, &doc)
+	if err != nil {
+		return fmt.Errorf("cannot parse the genesis: %v", err)
+	}
+
+	options := doc.AppOptions.PluginOptions
+	if len(options)%2 != 0 {
+		return fmt.Errorf("plugin_options must be a list of keys and values")
+	}
+
+	stakeStore, govStore := state.NewMemKVStore(), state.NewMemKVStore()
+	for i := 0; i < len(options); i += 2 {
+		var option string
+		err = json.Unmarshal(options[i], &option)
+		if err != nil {
+			return fmt.Errorf("option %d: the key must be a string", i/2)
+		}
+
+		// string values are unquoted, other JSON is passed as is
+		value := string(options[i+1])
+		var str string
+		if json.Unmarshal(options[i+1], &str) == nil {
+			value = str
+		}
+
+		module, key := option, ""
+		if j := strings.Index(option, "/"); j >= 0 {
+			module, key = option[:j], option[j+1:]
+		}
+		switch module {
+		case stake.Name():
+			_, err = stake.NewHandler().InitState(logger, stakeStore, module, key, value, nil)
+		case gov.Name():
+			_, err = gov.NewHandler().InitState(logger, govStore, module, key, value, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", option, err)
+		}
+	}
+	return nil
+}
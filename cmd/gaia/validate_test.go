@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateGenesis(t *testing.T) {
+	assert := assert.New(t)
+
+	genesis := func(options string) []byte {
+		return []byte(`{"chain_id": "test", "app_options": {"accounts": [], "plugin_options": [` +
+			options + `]}}`)
+	}
+
+	assert.Nil(validateGenesis(genesis(``)))
+	assert.Nil(validateGenesis(genesis(`"stake/allowed_bond_denom", "fermion",
+		"stake/genesis", {"params": {"max_vals": 10}},
+		"gov/voting_period", "100", "coin/issuer", {"app": "sigs"}`)))
+
+	// the options are checked in order, as the node sets them
+	testCases := []string{
+		`"stake/gas_unbond"`,
+		`"stake/max_vals", "many"`,
+		`"stake/unknown", "1"`,
+		`"stake/genesis", {"params": {"max_vals": 0}}`,
+		`"stake/genesis", {"params": {"unknown": 1}}`,
+		`"gov/threshold", "3/2"`,
+		`1, "fermion"`,
+	}
+	for _, tc := range testCases {
+		assert.NotNil(validateGenesis(genesis(tc)), tc)
+	}
+	assert.NotNil(validateGenesis([]byte(`not json`)))
+}
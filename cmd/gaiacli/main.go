@@ -23,6 +23,7 @@ import (
 	noncecmd "github.com/cosmos/cosmos-sdk/modules/nonce/commands"
 	rolecmd "github.com/cosmos/cosmos-sdk/modules/roles/commands"
 
+	govcmd "github.com/cosmos/gaia/modules/gov/commands"
 	stakecmd "github.com/cosmos/gaia/modules/stake/commands"
 	"github.com/cosmos/gaia/version"
 )
@@ -46,8 +47,15 @@ func main() {
 		rolecmd.RoleQueryCmd,
 		ibccmd.IBCQueryCmd,
 
-		//stakecmd.CmdQueryValidator,
+		stakecmd.CmdQueryValidator,
 		stakecmd.CmdQueryValidators,
+		stakecmd.CmdQueryValidatorSet,
+		stakecmd.CmdQueryNextValidators,
+		stakecmd.CmdQueryProvisions,
+		stakecmd.CmdQueryParams,
+
+		govcmd.CmdQueryProposal,
+		govcmd.CmdQueryVote,
 	)
 
 	// set up the middleware
@@ -72,8 +80,19 @@ func main() {
 		ibccmd.UpdateChainTxCmd,
 		ibccmd.PostPacketTxCmd,
 
+		stakecmd.CmdDeclareCandidacy,
+		stakecmd.CmdEditCandidacy,
 		stakecmd.CmdBond,
 		stakecmd.CmdUnbond,
+		stakecmd.CmdDelegate,
+		stakecmd.CmdUnbondDelegation,
+		stakecmd.CmdRedelegate,
+		stakecmd.CmdEditValidator,
+		stakecmd.CmdUnjail,
+
+		govcmd.CmdSubmitProposal,
+		govcmd.CmdDeposit,
+		govcmd.CmdVote,
 	)
 
 	// Set up the various commands to use
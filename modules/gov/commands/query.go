@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cosmos/gaia/modules/gov"
+
+	"github.com/cosmos/cosmos-sdk/client/commands"
+	"github.com/cosmos/cosmos-sdk/client/commands/query"
+	"github.com/cosmos/cosmos-sdk/stack"
+)
+
+// nolint
+var (
+	CmdQueryProposal = &cobra.Command{
+		Use:   "proposal [id]",
+		Short: "Query a governance proposal, with its status and tally",
+		RunE:  cmdQueryProposal,
+	}
+	CmdQueryVote = &cobra.Command{
+		Use:   "vote [id] [address]",
+		Short: "Query the vote of an account on a governance proposal",
+		RunE:  cmdQueryVote,
+	}
+)
+
+func cmdQueryProposal(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("must provide the id of the proposal")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	var proposal gov.Proposal
+	prove := !viper.GetBool(commands.FlagTrustNode)
+	key := stack.PrefixedKey(gov.Name(), gov.ProposalKey(id))
+	h, err := query.GetParsed(key, &proposal, query.GetHeight(), prove)
+	if err != nil {
+		return err
+	}
+
+	return query.OutputProof(proposal, h)
+}
+
+func cmdQueryVote(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("must provide the id of the proposal and the address of the voter")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	voter, err := commands.ParseActor(args[1])
+	if err != nil {
+		return err
+	}
+
+	var vote gov.Vote
+	prove := !viper.GetBool(commands.FlagTrustNode)
+	key := stack.PrefixedKey(gov.Name(), gov.VoteKey(id, voter))
+	h, err := query.GetParsed(key, &vote, query.GetHeight(), prove)
+	if err != nil {
+		return err
+	}
+
+	return query.OutputProof(vote, h)
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	txcmd "github.com/cosmos/cosmos-sdk/client/commands/txs"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+
+	"github.com/cosmos/gaia/modules/gov"
+)
+
+// nolint
+const (
+	FlagTitle       = "title"
+	FlagDescription = "description"
+	FlagChange      = "change"
+	FlagDeposit     = "deposit"
+	FlagProposalID  = "proposal-id"
+	FlagAmount      = "amount"
+	FlagOption      = "option"
+)
+
+// nolint
+var (
+	CmdSubmitProposal = &cobra.Command{
+		Use:   "submit-proposal",
+		Short: "submit a proposal to change the staking parameters, or a text proposal without --change",
+		RunE:  cmdSubmitProposal,
+	}
+	CmdDeposit = &cobra.Command{
+		Use:   "deposit",
+		Short: "deposit coins on a proposal which hasn't reached the minimum deposit",
+		RunE:  cmdDeposit,
+	}
+	CmdVote = &cobra.Command{
+		Use:   "vote",
+		Short: "vote on a proposal with the coins you have bonded",
+		RunE:  cmdVote,
+	}
+)
+
+func init() {
+	// Add Flags
+	fsProposal := flag.NewFlagSet("", flag.ContinueOnError)
+	fsProposal.Uint64(FlagProposalID, 0, "ID of the proposal")
+
+	CmdSubmitProposal.Flags().String(FlagTitle, "", "Title of the proposal")
+	CmdSubmitProposal.Flags().String(FlagDescription, "", "Description of the proposal")
+	CmdSubmitProposal.Flags().StringSlice(FlagChange, nil,
+		"Change of a staking parameter as key=value, with the key of the genesis option")
+	CmdSubmitProposal.Flags().String(FlagDeposit, "100fermion", "First deposit on the proposal")
+	CmdDeposit.Flags().AddFlagSet(fsProposal)
+	CmdDeposit.Flags().String(FlagAmount, "100fermion", "Amount to deposit")
+	CmdVote.Flags().AddFlagSet(fsProposal)
+	CmdVote.Flags().String(FlagOption, "", "Vote option, one of yes, no, abstain and veto")
+}
+
+func cmdSubmitProposal(cmd *cobra.Command, args []string) error {
+	deposit, err := coin.ParseCoin(viper.GetString(FlagDeposit))
+	if err != nil {
+		return err
+	}
+
+	var changes []gov.ParamChange
+	for _, change := range viper.GetStringSlice(FlagChange) {
+		kv := strings.SplitN(change, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("change must be of the form key=value, got %v", change)
+		}
+		changes = append(changes, gov.ParamChange{Key: kv[0], Value: kv[1]})
+	}
+
+	tx := gov.NewTxSubmitProposal(viper.GetString(FlagTitle),
+		viper.GetString(FlagDescription), changes, deposit)
+	return txcmd.DoTx(tx)
+}
+
+func cmdDeposit(cmd *cobra.Command, args []string) error {
+	amount, err := coin.ParseCoin(viper.GetString(FlagAmount))
+	if err != nil {
+		return err
+	}
+
+	tx := gov.NewTxDeposit(uint64(viper.GetInt64(FlagProposalID)), amount)
+	return txcmd.DoTx(tx)
+}
+
+func cmdVote(cmd *cobra.Command, args []string) error {
+	tx := gov.NewTxVote(uint64(viper.GetInt64(FlagProposalID)), viper.GetString(FlagOption))
+	return txcmd.DoTx(tx)
+}
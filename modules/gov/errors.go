@@ -0,0 +1,21 @@
+// nolint
+package gov
+
+import (
+	"fmt"
+
+	abci "github.com/tendermint/abci/types"
+)
+
+var (
+	errEmptyTitle        = fmt.Errorf("Proposal must have a title")
+	errBadDepositDenom   = fmt.Errorf("Invalid deposit denomination")
+	errBadVoteOption     = fmt.Errorf("Invalid vote option")
+	errNotDepositPeriod  = fmt.Errorf("Proposal is not in its deposit period")
+	errNotVotingPeriod   = fmt.Errorf("Proposal is not in its voting period")
+	errBadThreshold      = fmt.Errorf("Quorum and thresholds must be between 0 and 1")
+	errProposalNotExists = fmt.Errorf("Proposal does not exist")
+
+	resMissingSignature = abci.ErrBaseInvalidSignature.AppendLog("Missing signature")
+	resNoProposal       = abci.ErrBaseUnknownAddress.AppendLog("Proposal does not exist")
+)
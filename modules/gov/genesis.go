@@ -0,0 +1,161 @@
+package gov
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+// Genesis - the governance state of an exported chain, set with the "genesis"
+// option. The params replace any set by earlier options, those left out keep
+// their default value. The coins of the deposit account cannot be set in the
+// coin store by the genesis options, they are credited on the first block.
+type Genesis struct {
+	Params         Params           `json:"params"`
+	NextProposalID uint64           `json:"next_proposal_id"`
+	Proposals      []*Proposal      `json:"proposals"`
+	Deposits       []GenesisDeposit `json:"deposits"`
+	Votes          []GenesisVote    `json:"votes"`
+	DepositCoins   coin.Coins       `json:"deposit_coins"` // the coins of the deposit account
+}
+
+// GenesisDeposit - a deposit on the proposal with the id
+type GenesisDeposit struct {
+	ProposalID uint64 `json:"proposal_id"`
+	Deposit
+}
+
+// GenesisVote - a vote on the proposal with the id
+type GenesisVote struct {
+	ProposalID uint64 `json:"proposal_id"`
+	Vote
+}
+
+// ExportGenesis - the governance genesis restoring the state of the store,
+// along with the coins of the deposit account, which are held in the coin
+// store. The state is committed at height, the stored heights are moved back
+// by it so the first block of the new chain follows on from it.
+func ExportGenesis(store state.SimpleDB, depositCoins coin.Coins, height uint64) Genesis {
+	genesis := Genesis{
+		Params:         loadParams(store),
+		NextProposalID: loadNextProposalID(store),
+		DepositCoins:   depositCoins,
+	}
+
+	for id := uint64(1); id < genesis.NextProposalID; id++ {
+		proposal := loadProposal(store, id)
+		if proposal == nil {
+			continue
+		}
+		proposal.SubmitHeight = stake.ShiftHeight(proposal.SubmitHeight, height)
+		proposal.DepositEndHeight = stake.ShiftHeight(proposal.DepositEndHeight, height)
+		proposal.VotingEndHeight = stake.ShiftHeight(proposal.VotingEndHeight, height)
+		genesis.Proposals = append(genesis.Proposals, proposal)
+
+		for _, deposit := range loadDeposits(store, id) {
+			genesis.Deposits = append(genesis.Deposits, GenesisDeposit{id, *deposit})
+		}
+		for _, vote := range loadVotes(store, id) {
+			genesis.Votes = append(genesis.Votes, GenesisVote{id, *vote})
+		}
+	}
+	return genesis
+}
+
+// set the whole governance genesis from its JSON
+func initGenesis(store state.SimpleDB, value string) error {
+	genesis := Genesis{Params: defaultParams()}
+	err := json.Unmarshal([]byte(value), &genesis)
+	if err != nil {
+		return fmt.Errorf("gov genesis: %v", err)
+	}
+
+	// everything is checked on an empty store before any of it is set
+	err = genesis.apply(state.NewMemKVStore())
+	if err != nil {
+		return fmt.Errorf("gov genesis: %v", err)
+	}
+	return genesis.apply(store)
+}
+
+// set the params and restore the exported state
+func (genesis Genesis) apply(store state.SimpleDB) error {
+	for _, f := range []stake.Fraction{genesis.Params.Quorum,
+		genesis.Params.Threshold, genesis.Params.VetoThreshold} {
+
+		if f.Denom <= 0 || f.Num < 0 || f.Num > f.Denom {
+			return errBadThreshold
+		}
+	}
+	saveParams(store, genesis.Params)
+
+	if genesis.NextProposalID == 0 {
+		genesis.NextProposalID = 1
+	}
+	for _, proposal := range genesis.Proposals {
+		if proposal.ID == 0 || proposal.ID >= genesis.NextProposalID {
+			return fmt.Errorf("proposal id %d is not below the next proposal id", proposal.ID)
+		}
+		if loadProposal(store, proposal.ID) != nil {
+			return fmt.Errorf("proposal %d is set twice", proposal.ID)
+		}
+		saveProposal(store, proposal)
+		if proposal.Status == StatusDepositPeriod || proposal.Status == StatusVotingPeriod {
+			pushProposalQueue(store, proposal)
+		}
+	}
+	saveNextProposalID(store, genesis.NextProposalID)
+
+	for _, deposit := range genesis.Deposits {
+		if loadProposal(store, deposit.ProposalID) == nil {
+			return fmt.Errorf("deposit on the unknown proposal %d", deposit.ProposalID)
+		}
+		d := deposit.Deposit
+		saveDeposit(store, deposit.ProposalID, &d)
+	}
+	for _, vote := range genesis.Votes {
+		if loadProposal(store, vote.ProposalID) == nil {
+			return fmt.Errorf("vote on the unknown proposal %d", vote.ProposalID)
+		}
+		if !validVoteOption(vote.Option) {
+			return errBadVoteOption
+		}
+		v := vote.Vote
+		saveVote(store, vote.ProposalID, &v)
+	}
+
+	if len(genesis.DepositCoins) > 0 {
+		if !genesis.DepositCoins.IsValid() {
+			return fmt.Errorf("invalid coins %v", genesis.DepositCoins)
+		}
+		saveGenesisCoins(store, genesis.DepositCoins)
+	}
+	return nil
+}
+
+// ProcessGenesisCoins - credit the coins of the deposit account set by the
+// genesis, within coinStore, the store of the coin module. The genesis options
+// cannot reach the coin store, so it is done on the first block, before any
+// proposal can end. Does nothing afterwards.
+func ProcessGenesisCoins(store, coinStore state.SimpleDB) error {
+	return processGenesisCoins(store, storeChangeCoinsFn(coinStore))
+}
+
+// separated for testing
+func processGenesisCoins(store state.SimpleDB, mintFn changeCoinsFn) error {
+	coins := loadGenesisCoins(store)
+	if len(coins) == 0 {
+		return nil
+	}
+
+	res := mintFn(DepositAccount, coins)
+	if res.IsErr() {
+		return res
+	}
+	store.Remove(GenesisCoinsKey)
+	return nil
+}
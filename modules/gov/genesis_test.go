@@ -0,0 +1,59 @@
+package gov
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+func TestExportGenesis(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(2)
+
+	params := defaultParams()
+	params.MinDeposit = coin.Coin{"fermion", 10}
+	saveParams(store, params)
+
+	// a proposal being voted on and one waiting for the min deposit
+	tx := newTxSubmitProposal(10, ParamChange{"max_vals", "50"})
+	require.True(runTxSubmitProposal(store, actors[0], 5, dummyTransferFn(accStore), tx).IsOK())
+	require.True(runTxVote(store, actors[1], TxVote{1, OptionNo}).IsOK())
+	require.True(runTxSubmitProposal(store, actors[1], 5, dummyTransferFn(accStore), newTxSubmitProposal(4)).IsOK())
+	depositCoins := coin.Coins{{"fermion", 14}}
+
+	// the exported genesis restores the same state in a new store
+	bz, err := json.Marshal(ExportGenesis(store, depositCoins, 0))
+	require.Nil(err)
+	newStore := state.NewMemKVStore()
+	require.Nil(initGenesis(newStore, string(bz)), "%s", bz)
+	assert.Equal(depositCoins, loadGenesisCoins(newStore))
+	newStore.Remove(GenesisCoinsKey)
+	assert.Equal(store.List(nil, []byte{0xff}, 0), newStore.List(nil, []byte{0xff}, 0))
+
+	// the coins are credited to the deposit account once
+	require.Nil(processGenesisCoins(newStore, dummyBurnFn(accStore)))
+	assert.Nil(loadGenesisCoins(newStore))
+
+	// exported at a later height, the heights are moved back by it
+	genesis := ExportGenesis(store, depositCoins, 3)
+	require.Equal(2, len(genesis.Proposals))
+	assert.Equal(uint64(2), genesis.Proposals[0].SubmitHeight)
+	assert.Equal(loadProposal(store, 1).VotingEndHeight-3, genesis.Proposals[0].VotingEndHeight)
+	assert.Equal(loadProposal(store, 2).DepositEndHeight-3, genesis.Proposals[1].DepositEndHeight)
+
+	// the deposits and votes must be on known proposals
+	genesis.Proposals = genesis.Proposals[:1]
+	assert.NotNil(genesis.apply(state.NewMemKVStore()))
+	genesis.Deposits = genesis.Deposits[:1]
+	assert.Nil(genesis.apply(state.NewMemKVStore()))
+	genesis.NextProposalID = 1
+	assert.NotNil(genesis.apply(state.NewMemKVStore()))
+}
@@ -0,0 +1,458 @@
+package gov
+
+import (
+	"fmt"
+	"strconv"
+
+	abci "github.com/tendermint/abci/types"
+
+	"github.com/tendermint/tmlibs/log"
+
+	"github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/errors"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/stack"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+// nolint
+const (
+	govModuleName = "gov"
+)
+
+// Name is the name of the modules.
+func Name() string {
+	return govModuleName
+}
+
+// Handler - the transaction processing handler
+type Handler struct {
+	stack.PassInitValidate
+}
+
+// NewHandler returns a new Handler with the default Params.
+func NewHandler() Handler {
+	return Handler{}
+}
+
+var _ stack.Dispatchable = Handler{} // enforce interface at compile time
+
+// Name - return governance namespace
+func (Handler) Name() string {
+	return govModuleName
+}
+
+// AssertDispatcher - placeholder for stack.Dispatchable
+func (Handler) AssertDispatcher() {}
+
+// InitState - set genesis parameters for governance
+func (h Handler) InitState(l log.Logger, store state.SimpleDB,
+	module, key, value string, cb sdk.InitStater) (log string, err error) {
+	return "", h.initState(module, key, value, store)
+}
+
+// separated for testing
+func (Handler) initState(module, key, value string, store state.SimpleDB) error {
+	if module != govModuleName {
+		return errors.ErrUnknownModule(module)
+	}
+	if key == "genesis" {
+		return initGenesis(store, value)
+	}
+
+	params := loadParams(store)
+	switch key {
+	case "min_deposit":
+		deposit, err := coin.ParseCoin(value)
+		if err != nil {
+			return err
+		}
+		params.MinDeposit = deposit
+	case "quorum",
+		"threshold",
+		"veto_threshold":
+		f, err := stake.ParseFraction(value)
+		if err != nil {
+			return err
+		}
+		if f.Num < 0 || f.Num > f.Denom {
+			return errBadThreshold
+		}
+
+		switch key {
+		case "quorum":
+			params.Quorum = f
+		case "threshold":
+			params.Threshold = f
+		case "veto_threshold":
+			params.VetoThreshold = f
+		}
+	case "deposit_period",
+		"voting_period":
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("input must be integer, Error: %v", err.Error())
+		}
+
+		switch key {
+		case "deposit_period":
+			params.DepositPeriod = uint64(i)
+		case "voting_period":
+			params.VotingPeriod = uint64(i)
+		}
+	default:
+		return errors.ErrUnknownKey(key)
+	}
+
+	saveParams(store, params)
+	return nil
+}
+
+// CheckTx checks if the tx is properly structured
+func (h Handler) CheckTx(ctx sdk.Context, store state.SimpleDB,
+	tx sdk.Tx, _ sdk.Checker) (res sdk.CheckResult, err error) {
+
+	err = tx.ValidateBasic()
+	if err != nil {
+		return res, err
+	}
+
+	// get the sender
+	_, abciRes := getTxSender(ctx)
+	if abciRes.IsErr() {
+		return res, abciRes
+	}
+
+	switch txInner := tx.Unwrap().(type) {
+	case TxSubmitProposal:
+		return res, checkTxSubmitProposal(txInner, store)
+	case TxDeposit:
+		return res, checkTxDeposit(txInner, store)
+	case TxVote:
+		return res, checkTxVote(txInner, store)
+	}
+
+	return res, errors.ErrUnknownTxType("GTH")
+}
+
+func checkTxSubmitProposal(tx TxSubmitProposal, store state.SimpleDB) error {
+	if tx.Deposit.Denom != loadParams(store).MinDeposit.Denom {
+		return errBadDepositDenom
+	}
+
+	// the changes are checked together, on the stake params of the last block
+	if len(tx.Changes) > 0 {
+		if stakeParams, ok := loadStakeParams(store); ok {
+			keys, values := paramKeyValues(tx.Changes)
+			return stake.ValidateParams(stakeParams, keys, values)
+		}
+	}
+	return nil
+}
+
+func checkTxDeposit(tx TxDeposit, store state.SimpleDB) error {
+	if tx.Amount.Denom != loadParams(store).MinDeposit.Denom {
+		return errBadDepositDenom
+	}
+
+	proposal := loadProposal(store, tx.ProposalID)
+	if proposal == nil {
+		return errProposalNotExists
+	}
+	if proposal.Status != StatusDepositPeriod {
+		return errNotDepositPeriod
+	}
+	return nil
+}
+
+func checkTxVote(tx TxVote, store state.SimpleDB) error {
+	proposal := loadProposal(store, tx.ProposalID)
+	if proposal == nil {
+		return errProposalNotExists
+	}
+	if proposal.Status != StatusVotingPeriod {
+		return errNotVotingPeriod
+	}
+	return nil
+}
+
+// DeliverTx executes the tx if valid
+func (h Handler) DeliverTx(ctx sdk.Context, store state.SimpleDB,
+	tx sdk.Tx, dispatch sdk.Deliver) (res sdk.DeliverResult, err error) {
+
+	_, err = h.CheckTx(ctx, store, tx, nil)
+	if err != nil {
+		return
+	}
+
+	sender, abciRes := getTxSender(ctx)
+	if abciRes.IsErr() {
+		return res, abciRes
+	}
+
+	// Run the transaction
+	switch _tx := tx.Unwrap().(type) {
+	case TxSubmitProposal:
+		fn := defaultTransferFn(ctx, store, dispatch)
+		abciRes = runTxSubmitProposal(store, sender, ctx.BlockHeight(), fn, _tx)
+	case TxDeposit:
+		fn := defaultTransferFn(ctx, store, dispatch)
+		abciRes = runTxDeposit(store, sender, ctx.BlockHeight(), fn, _tx)
+	case TxVote:
+		abciRes = runTxVote(store, sender, _tx)
+	}
+
+	// a failed tx returns an error, so its partial writes are discarded
+	if abciRes.IsErr() {
+		return res, abciRes
+	}
+
+	res = sdk.DeliverResult{
+		Data: abciRes.Data,
+		Log:  abciRes.Log,
+	}
+	return
+}
+
+// -------------------------------------------------------------
+// these functions assume everything has been authenticated,
+// now we just run the tx and save
+
+func runTxSubmitProposal(store state.SimpleDB, sender sdk.Actor, height uint64,
+	transferFn transferFn, tx TxSubmitProposal) (res abci.Result) {
+
+	// the deposit is taken before the proposal is stored or gets an id
+	res = transferFn(sender, DepositAccount, coin.Coins{tx.Deposit})
+	if res.IsErr() {
+		return res
+	}
+
+	params := loadParams(store)
+	proposal := &Proposal{
+		ID:               nextProposalID(store),
+		Title:            tx.Title,
+		Description:      tx.Description,
+		Changes:          tx.Changes,
+		Proposer:         sender,
+		Status:           StatusDepositPeriod,
+		SubmitHeight:     height,
+		DepositEndHeight: height + params.DepositPeriod,
+		TotalDeposit:     coin.Coin{params.MinDeposit.Denom, 0},
+	}
+	saveProposal(store, proposal)
+	pushProposalQueue(store, proposal)
+	addDeposit(store, proposal, sender, height, tx.Deposit)
+
+	// the id of the new proposal is returned
+	res.Data = []byte(strconv.FormatUint(proposal.ID, 10))
+	return res
+}
+
+func runTxDeposit(store state.SimpleDB, sender sdk.Actor, height uint64,
+	transferFn transferFn, tx TxDeposit) (res abci.Result) {
+
+	proposal := loadProposal(store, tx.ProposalID)
+	if proposal == nil {
+		return resNoProposal
+	}
+
+	res = transferFn(sender, DepositAccount, coin.Coins{tx.Amount})
+	if res.IsErr() {
+		return res
+	}
+	addDeposit(store, proposal, sender, height, tx.Amount)
+	return abci.OK
+}
+
+func runTxVote(store state.SimpleDB, sender sdk.Actor, tx TxVote) (res abci.Result) {
+	if loadProposal(store, tx.ProposalID) == nil {
+		return resNoProposal
+	}
+
+	saveVote(store, tx.ProposalID, &Vote{
+		Voter:  sender,
+		Option: tx.Option,
+	})
+	return abci.OK
+}
+
+// addDeposit records the coins moved from the depositor to the deposit
+// account, the voting period starts once the deposits reach the min deposit
+func addDeposit(store state.SimpleDB, proposal *Proposal, depositor sdk.Actor,
+	height uint64, amount coin.Coin) {
+
+	d := loadDeposit(store, proposal.ID, depositor)
+	if d == nil {
+		d = &Deposit{Depositor: depositor, Amount: coin.Coin{amount.Denom, 0}}
+	}
+	d.Amount.Amount += amount.Amount
+	saveDeposit(store, proposal.ID, d)
+
+	params := loadParams(store)
+	proposal.TotalDeposit.Amount += amount.Amount
+	if proposal.TotalDeposit.Amount >= params.MinDeposit.Amount {
+		removeProposalQueue(store, proposal)
+		proposal.Status = StatusVotingPeriod
+		proposal.VotingEndHeight = height + params.VotingPeriod
+		pushProposalQueue(store, proposal)
+	}
+
+	saveProposal(store, proposal)
+}
+
+// the voting power of a voter
+type votingPowerFn func(voter sdk.Actor) uint64
+
+// the voting power of a voter is the coins it has bonded within stakeStore,
+// the store of the stake module
+func stakeVotingPowerFn(stakeStore state.SimpleDB) votingPowerFn {
+	return func(voter sdk.Actor) uint64 {
+		return stake.BondedCoins(stakeStore, voter)
+	}
+}
+
+// the coins bonded in total, the most voting power which can vote
+type totalPowerFn func() uint64
+
+// the total is the coins bonded to all the validator bonds within
+// stakeStore, the store of the stake module
+func stakeTotalPowerFn(stakeStore state.SimpleDB) totalPowerFn {
+	return func() uint64 {
+		return stake.TotalBondedCoins(stakeStore)
+	}
+}
+
+// apply the changes of the stake params together, none are applied if the
+// params they result in are invalid
+type setParamsFn func(keys, values []string) error
+
+// ProcessProposals - end the periods of the proposals ending at this height.
+// Proposals which haven't reached the min deposit are dropped, the votes on the
+// others are tallied by the coins each voter has bonded within stakeStore, the
+// store of the stake module, and the changes of the passed proposals are
+// applied to the stake params, or the proposal is rejected if they are invalid
+// for the current params. The stake params are then copied to store, for the
+// proposals submitted next. The deposits are returned, or burned if the
+// proposal was vetoed, within coinStore, the store of the coin module. Burned
+// deposits are removed from the supply of the bond denom.
+func ProcessProposals(store, stakeStore, coinStore state.SimpleDB, height uint64) error {
+	setParams := func(keys, values []string) error {
+		return stake.SetParams(stakeStore, keys, values)
+	}
+	// the burned deposits leave the supply tracked by the stake module
+	burn := func(addr sdk.Actor, coins coin.Coins) abci.Result {
+		res := storeChangeCoinsFn(coinStore)(addr, coins)
+		if res.IsOK() {
+			stake.BurnSupply(stakeStore, coins.Negative())
+		}
+		return res
+	}
+	err := processProposals(store, storeTransferFn(coinStore), burn,
+		stakeVotingPowerFn(stakeStore), stakeTotalPowerFn(stakeStore), setParams, height)
+	if err != nil {
+		return err
+	}
+
+	// CheckTx only has the governance store, the stake params the new
+	// proposals are checked against are copied to it
+	saveStakeParams(store, stake.LoadParams(stakeStore))
+	return nil
+}
+
+// separated for testing
+func processProposals(store state.SimpleDB, transferFn transferFn, burnFn changeCoinsFn,
+	powerFn votingPowerFn, totalPowerFn totalPowerFn, setParamsFn setParamsFn, height uint64) error {
+
+	params := loadParams(store)
+	ids, keys := loadProposalQueue(store, height)
+	for i, id := range ids {
+		store.Remove(keys[i])
+		proposal := loadProposal(store, id)
+		if proposal == nil {
+			continue // an entry left without its proposal is only dropped
+		}
+
+		switch proposal.Status {
+		case StatusDepositPeriod:
+			proposal.Status = StatusDropped
+		case StatusVotingPeriod:
+			proposal.Tally = tally(store, id, powerFn)
+			proposal.Tally.TotalBonded = totalPowerFn()
+			proposal.Status = proposal.Tally.status(params)
+		}
+
+		// the changes were validated on the stake params when the proposal
+		// was submitted, the stake params may have changed since
+		if proposal.Status == StatusPassed && len(proposal.Changes) > 0 {
+			if setParamsFn(paramKeyValues(proposal.Changes)) != nil {
+				proposal.Status = StatusRejected
+			}
+		}
+
+		var err error
+		if proposal.Status == StatusVetoed {
+			err = burnDeposits(store, burnFn, id)
+		} else {
+			err = returnDeposits(store, transferFn, id)
+		}
+		if err != nil {
+			return err
+		}
+		saveProposal(store, proposal)
+	}
+	return nil
+}
+
+// sum the voting power of the votes for each option
+func tally(store state.SimpleDB, id uint64, powerFn votingPowerFn) (result TallyResult) {
+	for _, vote := range loadVotes(store, id) {
+		power := powerFn(vote.Voter)
+		switch vote.Option {
+		case OptionYes:
+			result.Yes += power
+		case OptionNo:
+			result.No += power
+		case OptionAbstain:
+			result.Abstain += power
+		case OptionVeto:
+			result.Veto += power
+		}
+	}
+	return
+}
+
+// return the deposits on a proposal to the depositors
+func returnDeposits(store state.SimpleDB, transferFn transferFn, id uint64) error {
+	for _, d := range loadDeposits(store, id) {
+		res := transferFn(DepositAccount, d.Depositor, coin.Coins{d.Amount})
+		if res.IsErr() {
+			return res
+		}
+		removeDeposit(store, id, d)
+	}
+	return nil
+}
+
+// burn the deposits on a vetoed proposal
+func burnDeposits(store state.SimpleDB, burnFn changeCoinsFn, id uint64) error {
+	for _, d := range loadDeposits(store, id) {
+		res := burnFn(DepositAccount, coin.Coins{d.Amount}.Negative())
+		if res.IsErr() {
+			return res
+		}
+		removeDeposit(store, id, d)
+	}
+	return nil
+}
+
+// get the sender from the ctx
+func getTxSender(ctx sdk.Context) (sender sdk.Actor, res abci.Result) {
+	senders := ctx.GetPermissions("", auth.NameSigs)
+	if len(senders) != 1 {
+		return sender, resMissingSignature
+	}
+	return senders[0], abci.OK
+}
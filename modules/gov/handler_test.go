@@ -0,0 +1,455 @@
+package gov
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/go-wire"
+
+	"github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/stack"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+func dummyTransferFn(store map[string]int64) transferFn {
+	return func(from, to sdk.Actor, coins coin.Coins) abci.Result {
+		store[string(from.Address)] -= int64(coins[0].Amount)
+		store[string(to.Address)] += int64(coins[0].Amount)
+		return abci.OK
+	}
+}
+
+func dummyPowerFn(powers map[string]uint64) votingPowerFn {
+	return func(voter sdk.Actor) uint64 {
+		return powers[string(voter.Address)]
+	}
+}
+
+func dummyBurnFn(store map[string]int64) changeCoinsFn {
+	return func(addr sdk.Actor, coins coin.Coins) abci.Result {
+		store[string(addr.Address)] += int64(coins[0].Amount)
+		return abci.OK
+	}
+}
+
+// the total is the voting power of the powers
+func dummyTotalPowerFn(powers map[string]uint64) totalPowerFn {
+	return func() (total uint64) {
+		for _, power := range powers {
+			total += power
+		}
+		return
+	}
+}
+
+func dummySetParamsFn(params map[string]string) setParamsFn {
+	return func(keys, values []string) error {
+		for i, key := range keys {
+			params[key] = values[i]
+		}
+		return nil
+	}
+}
+
+func newActors(n int) (actors []sdk.Actor) {
+	for i := 0; i < n; i++ {
+		actors = append(actors, sdk.NewActor("testapp", []byte{byte(i + 1)}))
+	}
+	return
+}
+
+func newTxSubmitProposal(deposit int64, changes ...ParamChange) TxSubmitProposal {
+	return TxSubmitProposal{
+		Title:       "title",
+		Description: "description",
+		Changes:     changes,
+		Deposit:     coin.Coin{"fermion", deposit},
+	}
+}
+
+func TestSubmitProposalValidation(t *testing.T) {
+	assert := assert.New(t)
+	store := state.NewMemKVStore()
+
+	// the changes are validated as genesis options of the stake module
+	tx := newTxSubmitProposal(10, ParamChange{"max_vals", "50"})
+	assert.Nil(tx.ValidateBasic())
+	assert.Nil(checkTxSubmitProposal(tx, store))
+
+	assert.NotNil(newTxSubmitProposal(10, ParamChange{"max_vals", "many"}).ValidateBasic())
+	assert.NotNil(newTxSubmitProposal(10, ParamChange{"goal_bonded", "3/2"}).ValidateBasic())
+	assert.NotNil(newTxSubmitProposal(10, ParamChange{"unknown", "1"}).ValidateBasic())
+	assert.NotNil(newTxSubmitProposal(10, ParamChange{"epoch_length", "0"}).ValidateBasic())
+
+	// the changes are checked together by CheckTx, on the stake params of the
+	// last block, ValidateBasic only checks each change
+	valid := newTxSubmitProposal(10, ParamChange{"inflation_rate_max", "3/5"},
+		ParamChange{"inflation_rate_min", "1/2"})
+	invalid := newTxSubmitProposal(10, ParamChange{"inflation_rate_max", "1/2"},
+		ParamChange{"inflation_rate_min", "3/5"})
+	assert.Nil(valid.ValidateBasic())
+	assert.Nil(invalid.ValidateBasic())
+	assert.Nil(checkTxSubmitProposal(invalid, store)) // no block yet
+	saveStakeParams(store, stake.LoadParams(state.NewMemKVStore()))
+	assert.Nil(checkTxSubmitProposal(valid, store))
+	assert.NotNil(checkTxSubmitProposal(invalid, store))
+	assert.Nil(newTxSubmitProposal(10).ValidateBasic()) // a text proposal
+	assert.NotNil(newTxSubmitProposal(0, ParamChange{"max_vals", "50"}).ValidateBasic())
+
+	tx.Title = ""
+	assert.Equal(errEmptyTitle, tx.ValidateBasic())
+
+	tx = newTxSubmitProposal(10, ParamChange{"max_vals", "50"})
+	tx.Deposit.Denom = "strings"
+	assert.Equal(errBadDepositDenom, checkTxSubmitProposal(tx, store))
+}
+
+type failingDeliver struct{}
+
+func (failingDeliver) DeliverTx(ctx sdk.Context, store state.SimpleDB,
+	tx sdk.Tx) (sdk.DeliverResult, error) {
+
+	return sdk.DeliverResult{}, fmt.Errorf("insufficient funds")
+}
+
+func TestSubmitProposalInsufficientCoins(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store, coinStore := state.NewMemKVStore(), state.NewMemKVStore()
+	proposer := newActors(1)[0]
+	_, err := coin.ChangeCoins(coinStore, proposer, coin.Coins{{"fermion", 5}})
+	require.Nil(err)
+
+	// nothing is stored and no id is used up if the deposit can't be paid
+	tx := newTxSubmitProposal(10, ParamChange{"max_vals", "50"})
+	res := runTxSubmitProposal(store, proposer, 1, storeTransferFn(coinStore), tx)
+	assert.True(res.IsErr())
+	assert.Nil(loadProposal(store, 1))
+	assert.Empty(loadDeposits(store, 1))
+	ids, _ := loadProposalQueue(store, 1000)
+	assert.Empty(ids)
+	assert.Nil(store.Get(NextProposalIDKey))
+
+	// the handler returns the failure as an error, so the app discards the tx
+	ctx := stack.MockContext("testChain", 1).WithPermissions(auth.SigPerm(proposer.Address))
+	_, err = Handler{}.DeliverTx(ctx, store, tx.Wrap(), failingDeliver{})
+	assert.NotNil(err)
+	assert.Nil(loadProposal(store, 1))
+}
+
+func TestProposalPasses(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(3)
+	proposer, depositor, voter := actors[0], actors[1], actors[2]
+	powers := map[string]uint64{
+		string(proposer.Address):  300,
+		string(depositor.Address): 200,
+		string(voter.Address):     150,
+	}
+	stakeParams := map[string]string{}
+
+	params := defaultParams()
+	params.DepositPeriod = 10
+	params.VotingPeriod = 20
+	saveParams(store, params)
+
+	// the proposal waits for the min deposit
+	tx := newTxSubmitProposal(40, ParamChange{"max_vals", "50"})
+	res := runTxSubmitProposal(store, proposer, 1, dummyTransferFn(accStore), tx)
+	require.True(res.IsOK(), "%v", res)
+	assert.Equal("1", string(res.Data))
+	proposal := loadProposal(store, 1)
+	require.NotNil(proposal)
+	assert.Equal(StatusDepositPeriod, proposal.Status)
+	assert.Equal(int64(40), accStore[string(DepositAccount.Address)])
+	assert.Equal(errNotVotingPeriod, checkTxVote(TxVote{1, OptionYes}, store))
+
+	// once reached the voting period starts
+	txDeposit := TxDeposit{1, coin.Coin{"fermion", 60}}
+	require.Nil(checkTxDeposit(txDeposit, store))
+	res = runTxDeposit(store, depositor, 5, dummyTransferFn(accStore), txDeposit)
+	require.True(res.IsOK(), "%v", res)
+	proposal = loadProposal(store, 1)
+	assert.Equal(StatusVotingPeriod, proposal.Status)
+	assert.Equal(uint64(25), proposal.VotingEndHeight)
+	assert.Equal(int64(100), proposal.TotalDeposit.Amount)
+	assert.Equal(errNotDepositPeriod, checkTxDeposit(txDeposit, store))
+
+	// the deposit period ending doesn't affect it anymore
+	err := processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(powers), dummyTotalPowerFn(powers), dummySetParamsFn(stakeParams), 11)
+	require.Nil(err)
+	assert.Equal(StatusVotingPeriod, loadProposal(store, 1).Status)
+
+	// the votes are weighted by the voting power, a later vote replaces
+	require.Nil(checkTxVote(TxVote{1, OptionYes}, store))
+	require.True(runTxVote(store, proposer, TxVote{1, OptionNo}).IsOK())
+	require.True(runTxVote(store, proposer, TxVote{1, OptionYes}).IsOK())
+	require.True(runTxVote(store, depositor, TxVote{1, OptionNo}).IsOK())
+	require.True(runTxVote(store, voter, TxVote{1, OptionYes}).IsOK())
+
+	err = processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(powers), dummyTotalPowerFn(powers), dummySetParamsFn(stakeParams), 24)
+	require.Nil(err)
+	assert.Empty(stakeParams)
+
+	err = processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(powers), dummyTotalPowerFn(powers), dummySetParamsFn(stakeParams), 25)
+	require.Nil(err)
+	proposal = loadProposal(store, 1)
+	assert.Equal(StatusPassed, proposal.Status)
+	assert.Equal(TallyResult{Yes: 450, No: 200, TotalBonded: 650}, proposal.Tally)
+	assert.Equal(map[string]string{"max_vals": "50"}, stakeParams)
+
+	// the deposits are returned
+	assert.Equal(int64(0), accStore[string(DepositAccount.Address)])
+	assert.Equal(int64(0), accStore[string(proposer.Address)])
+	assert.Empty(loadDeposits(store, 1))
+}
+
+func TestProposalDropped(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	proposer := newActors(1)[0]
+	stakeParams := map[string]string{}
+
+	params := defaultParams()
+	params.DepositPeriod = 10
+	saveParams(store, params)
+
+	tx := newTxSubmitProposal(40, ParamChange{"max_vals", "50"})
+	require.True(runTxSubmitProposal(store, proposer, 1, dummyTransferFn(accStore), tx).IsOK())
+
+	err := processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(nil), dummyTotalPowerFn(nil), dummySetParamsFn(stakeParams), 11)
+	require.Nil(err)
+	assert.Equal(StatusDropped, loadProposal(store, 1).Status)
+	assert.Equal(int64(0), accStore[string(proposer.Address)])
+	assert.Empty(stakeParams)
+	ids, _ := loadProposalQueue(store, 1000)
+	assert.Empty(ids)
+}
+
+func TestProposalRejected(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(2)
+	powers := map[string]uint64{
+		string(actors[0].Address): 100,
+		string(actors[1].Address): 100,
+	}
+	stakeParams := map[string]string{}
+
+	params := defaultParams()
+	params.MinDeposit = coin.Coin{"fermion", 10}
+	params.VotingPeriod = 5
+	saveParams(store, params)
+
+	// half of the voting power isn't more than the threshold
+	tx := newTxSubmitProposal(10, ParamChange{"max_vals", "50"})
+	require.True(runTxSubmitProposal(store, actors[0], 1, dummyTransferFn(accStore), tx).IsOK())
+	require.True(runTxVote(store, actors[0], TxVote{1, OptionYes}).IsOK())
+	require.True(runTxVote(store, actors[1], TxVote{1, OptionNo}).IsOK())
+
+	err := processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(powers), dummyTotalPowerFn(powers), dummySetParamsFn(stakeParams), 6)
+	require.Nil(err)
+	assert.Equal(StatusRejected, loadProposal(store, 1).Status)
+	assert.Empty(stakeParams)
+	assert.Equal(int64(0), accStore[string(actors[0].Address)])
+}
+
+func TestProcessProposalsStakeParams(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store, stakeStore := state.NewMemKVStore(), state.NewMemKVStore()
+	accStore := map[string]int64{}
+	proposer := newActors(1)[0]
+
+	params := defaultParams()
+	params.MinDeposit = coin.Coin{"fermion", 10}
+	params.VotingPeriod = 5
+	saveParams(store, params)
+
+	tx := newTxSubmitProposal(10, ParamChange{"max_vals", "50"}, ParamChange{"unbonding_period", "7"})
+	require.True(runTxSubmitProposal(store, proposer, 1, dummyTransferFn(accStore), tx).IsOK())
+	require.True(runTxVote(store, proposer, TxVote{1, OptionYes}).IsOK())
+
+	// the changes are applied to the params in the stake store
+	setParams := func(keys, values []string) error {
+		return stake.SetParams(stakeStore, keys, values)
+	}
+	powers := map[string]uint64{string(proposer.Address): 1}
+	err := processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(powers), dummyTotalPowerFn(powers), setParams, 6)
+	require.Nil(err)
+	assert.Equal(StatusPassed, loadProposal(store, 1).Status)
+
+	var stakeParams stake.Params
+	require.Nil(wire.ReadBinaryBytes(stakeStore.Get(stake.ParamKey), &stakeParams))
+	assert.Equal(50, stakeParams.MaxVals)
+	assert.Equal(uint64(7), stakeParams.UnbondingPeriod)
+}
+
+func TestProposalInvalidStakeParams(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store, stakeStore := state.NewMemKVStore(), state.NewMemKVStore()
+	accStore := map[string]int64{}
+	proposer := newActors(1)[0]
+	require.Nil(stake.SetParams(stakeStore, []string{"inflation_rate_min", "inflation_rate_max"},
+		[]string{"1/100", "1/10"}))
+
+	params := defaultParams()
+	params.MinDeposit = coin.Coin{"fermion", 10}
+	params.VotingPeriod = 5
+	saveParams(store, params)
+
+	// the change is valid on the params it was checked against, but not on
+	// the current ones
+	tx := newTxSubmitProposal(10, ParamChange{"max_vals", "50"}, ParamChange{"inflation_rate_min", "3/20"})
+	require.Nil(tx.ValidateBasic())
+	saveStakeParams(store, stake.LoadParams(state.NewMemKVStore()))
+	require.Nil(checkTxSubmitProposal(tx, store))
+	require.True(runTxSubmitProposal(store, proposer, 1, dummyTransferFn(accStore), tx).IsOK())
+	require.True(runTxVote(store, proposer, TxVote{1, OptionYes}).IsOK())
+
+	setParams := func(keys, values []string) error {
+		return stake.SetParams(stakeStore, keys, values)
+	}
+	powers := map[string]uint64{string(proposer.Address): 1}
+	err := processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(powers), dummyTotalPowerFn(powers), setParams, 6)
+	require.Nil(err)
+	assert.Equal(StatusRejected, loadProposal(store, 1).Status)
+	assert.Equal(int64(0), accStore[string(proposer.Address)])
+
+	// none of the changes are applied
+	var stakeParams stake.Params
+	require.Nil(wire.ReadBinaryBytes(stakeStore.Get(stake.ParamKey), &stakeParams))
+	assert.Equal(100, stakeParams.MaxVals)
+	assert.Equal(stake.NewFraction(1, 100), stakeParams.InflationRateMin)
+
+	// the stake params are copied for CheckTx at the end of the block, which
+	// rejects the proposal from then on
+	require.Nil(ProcessProposals(store, stakeStore, state.NewMemKVStore(), 7))
+	copied, ok := loadStakeParams(store)
+	require.True(ok)
+	assert.Equal(stakeParams, copied)
+	assert.NotNil(checkTxSubmitProposal(tx, store))
+}
+
+func TestProcessProposalsQueueWithoutProposal(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	stakeParams := map[string]string{}
+
+	// an entry whose proposal isn't stored is removed from the queue
+	pushProposalQueue(store, &Proposal{ID: 7, Status: StatusDepositPeriod, DepositEndHeight: 3})
+	err := processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(nil), dummyTotalPowerFn(nil), dummySetParamsFn(stakeParams), 5)
+	require.Nil(err)
+	ids, _ := loadProposalQueue(store, 1000)
+	assert.Empty(ids)
+	assert.Nil(loadProposal(store, 7))
+}
+
+func TestProposalVetoed(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(4)
+	powers := map[string]uint64{
+		string(actors[0].Address): 500,
+		string(actors[1].Address): 100,
+		string(actors[2].Address): 300,
+		string(actors[3].Address): 100,
+	}
+	stakeParams := map[string]string{}
+
+	params := defaultParams()
+	params.MinDeposit = coin.Coin{"fermion", 10}
+	params.VotingPeriod = 5
+	saveParams(store, params)
+
+	// a text proposal and a param change, submitted by the same account
+	require.True(runTxSubmitProposal(store, actors[0], 1, dummyTransferFn(accStore),
+		newTxSubmitProposal(10)).IsOK())
+	require.True(runTxSubmitProposal(store, actors[0], 1, dummyTransferFn(accStore),
+		newTxSubmitProposal(20, ParamChange{"max_vals", "50"})).IsOK())
+	assert.Equal(int64(30), accStore[string(DepositAccount.Address)])
+
+	// the text proposal passes as the abstain vote isn't counted against it
+	require.True(runTxVote(store, actors[0], TxVote{1, OptionYes}).IsOK())
+	require.True(runTxVote(store, actors[1], TxVote{1, OptionNo}).IsOK())
+	require.True(runTxVote(store, actors[2], TxVote{1, OptionAbstain}).IsOK())
+
+	// the param change has a majority, but more than a third of the votes veto
+	require.True(runTxVote(store, actors[0], TxVote{2, OptionYes}).IsOK())
+	require.True(runTxVote(store, actors[2], TxVote{2, OptionVeto}).IsOK())
+	require.True(runTxVote(store, actors[3], TxVote{2, OptionVeto}).IsOK())
+
+	err := processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(powers), dummyTotalPowerFn(powers), dummySetParamsFn(stakeParams), 6)
+	require.Nil(err)
+
+	proposal := loadProposal(store, 1)
+	assert.Equal(StatusPassed, proposal.Status)
+	assert.Equal(TallyResult{Yes: 500, No: 100, Abstain: 300, TotalBonded: 1000}, proposal.Tally)
+	proposal = loadProposal(store, 2)
+	assert.Equal(StatusVetoed, proposal.Status)
+	assert.Empty(stakeParams)
+
+	// the deposit of the text proposal is returned, the other one burned
+	assert.Equal(int64(-20), accStore[string(actors[0].Address)])
+	assert.Equal(int64(0), accStore[string(DepositAccount.Address)])
+	assert.Empty(loadDeposits(store, 2))
+}
+
+func TestProposalQuorum(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(2)
+	powers := map[string]uint64{
+		string(actors[0].Address): 100,
+		string(actors[1].Address): 300,
+	}
+	stakeParams := map[string]string{}
+
+	params := defaultParams()
+	params.MinDeposit = coin.Coin{"fermion", 10}
+	params.VotingPeriod = 5
+	saveParams(store, params)
+
+	// a quarter of the bonded coins vote, less than the quorum of a third
+	tx := newTxSubmitProposal(10, ParamChange{"max_vals", "50"})
+	require.True(runTxSubmitProposal(store, actors[0], 1, dummyTransferFn(accStore), tx).IsOK())
+	require.True(runTxVote(store, actors[0], TxVote{1, OptionYes}).IsOK())
+
+	err := processProposals(store, dummyTransferFn(accStore), dummyBurnFn(accStore),
+		dummyPowerFn(powers), dummyTotalPowerFn(powers), dummySetParamsFn(stakeParams), 6)
+	require.Nil(err)
+	assert.Equal(StatusRejected, loadProposal(store, 1).Status)
+	assert.Empty(stakeParams)
+	assert.Equal(int64(0), accStore[string(actors[0].Address)])
+}
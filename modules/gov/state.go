@@ -0,0 +1,269 @@
+package gov
+
+import (
+	"encoding/binary"
+
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/go-wire"
+
+	"github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+// move coins from one account to another
+type transferFn func(sender, receiver sdk.Actor, coins coin.Coins) abci.Result
+
+// transfer coins by running a SendTx through the dispatcher
+func defaultTransferFn(ctx sdk.Context, store state.SimpleDB, dispatch sdk.Deliver) transferFn {
+	return func(sender, receiver sdk.Actor, coins coin.Coins) (res abci.Result) {
+		send := coin.NewSendOneTx(sender, receiver, coins)
+
+		// If the deduction fails (too high), abort the command
+		_, err := dispatch.DeliverTx(ctx, store, send)
+		if err != nil {
+			return abci.ErrInsufficientFunds.AppendLog(err.Error())
+		}
+		return abci.OK
+	}
+}
+
+// transfer coins by writing directly to the coin store, for use from the tick
+func storeTransferFn(coinStore state.SimpleDB) transferFn {
+	return func(sender, receiver sdk.Actor, coins coin.Coins) (res abci.Result) {
+		_, err := coin.ChangeCoins(coinStore, sender, coins.Negative())
+		if err != nil {
+			return abci.ErrInsufficientFunds.AppendLog(err.Error())
+		}
+
+		_, err = coin.ChangeCoins(coinStore, receiver, coins)
+		if err != nil {
+			return abci.ErrInternalError.AppendLog(err.Error())
+		}
+		return abci.OK
+	}
+}
+
+// add or remove coins from an account
+type changeCoinsFn func(addr sdk.Actor, coins coin.Coins) abci.Result
+
+// change coins by writing directly to the coin store, used from the tick
+func storeChangeCoinsFn(coinStore state.SimpleDB) changeCoinsFn {
+	return func(addr sdk.Actor, coins coin.Coins) (res abci.Result) {
+		_, err := coin.ChangeCoins(coinStore, addr, coins)
+		if err != nil {
+			return abci.ErrInternalError.AppendLog(err.Error())
+		}
+		return abci.OK
+	}
+}
+
+// DepositAccount - the account holding the deposits until the proposals end
+var DepositAccount = sdk.NewActor(govModuleName, []byte("deposits"))
+
+// nolint - state keys for the governance store
+var (
+	ParamKey            = []byte{0x00} // key for the governance params
+	NextProposalIDKey   = []byte{0x01} // key for the id of the next proposal
+	ProposalKeyPrefix   = []byte{0x02} // prefix for each key to a proposal
+	DepositKeyPrefix    = []byte{0x03} // prefix for each key to a deposit
+	VoteKeyPrefix       = []byte{0x04} // prefix for each key to a vote
+	ProposalQueuePrefix = []byte{0x05} // prefix for the proposals by the end of their period
+	GenesisCoinsKey     = []byte{0x06} // key for the coins of the deposit account set by the genesis
+	StakeParamsKey      = []byte{0x07} // key for the stake params of the last block
+)
+
+// ProposalKey - state key for a proposal
+func ProposalKey(id uint64) []byte {
+	return appendID(ProposalKeyPrefix, id)
+}
+
+// DepositKey - state key for the deposit of a depositor on a proposal
+func DepositKey(id uint64, depositor sdk.Actor) []byte {
+	return append(appendID(DepositKeyPrefix, id), wire.BinaryBytes(&depositor)...)
+}
+
+// VoteKey - state key for the vote of a voter on a proposal
+func VoteKey(id uint64, voter sdk.Actor) []byte {
+	return append(appendID(VoteKeyPrefix, id), wire.BinaryBytes(&voter)...)
+}
+
+// the queue is ordered by the height the period of the proposal ends at
+func proposalQueueKey(height, id uint64) []byte {
+	return appendID(appendID(ProposalQueuePrefix, height), id)
+}
+
+// append a big endian number, so the keys are ordered by it
+func appendID(prefix []byte, id uint64) []byte {
+	key := make([]byte, len(prefix)+8)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], id)
+	return key
+}
+
+// load/save the governance params
+func loadParams(store state.SimpleDB) (params Params) {
+	b := store.Get(ParamKey)
+	if b == nil {
+		return defaultParams()
+	}
+
+	err := wire.ReadBinaryBytes(b, &params)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+	return
+}
+func saveParams(store state.SimpleDB, params Params) {
+	store.Set(ParamKey, wire.BinaryBytes(params))
+}
+
+// load/save the stake params of the last block, loading reports false before
+// the first block
+func loadStakeParams(store state.SimpleDB) (params stake.Params, ok bool) {
+	b := store.Get(StakeParamsKey)
+	if b == nil {
+		return params, false
+	}
+
+	err := wire.ReadBinaryBytes(b, &params)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+	return params, true
+}
+func saveStakeParams(store state.SimpleDB, params stake.Params) {
+	store.Set(StakeParamsKey, wire.BinaryBytes(params))
+}
+
+// get the id for a new proposal, ids start at 1
+func nextProposalID(store state.SimpleDB) uint64 {
+	id := loadNextProposalID(store)
+	saveNextProposalID(store, id+1)
+	return id
+}
+
+// load/save the id the next proposal will get
+func loadNextProposalID(store state.SimpleDB) uint64 {
+	b := store.Get(NextProposalIDKey)
+	if b == nil {
+		return 1
+	}
+	return binary.BigEndian.Uint64(b)
+}
+func saveNextProposalID(store state.SimpleDB, id uint64) {
+	next := make([]byte, 8)
+	binary.BigEndian.PutUint64(next, id)
+	store.Set(NextProposalIDKey, next)
+}
+
+// load/save a proposal
+func loadProposal(store state.SimpleDB, id uint64) *Proposal {
+	b := store.Get(ProposalKey(id))
+	if b == nil {
+		return nil
+	}
+
+	proposal := new(Proposal)
+	err := wire.ReadBinaryBytes(b, proposal)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+	return proposal
+}
+func saveProposal(store state.SimpleDB, proposal *Proposal) {
+	store.Set(ProposalKey(proposal.ID), wire.BinaryBytes(*proposal))
+}
+
+// load/save/remove the deposits on a proposal
+func loadDeposit(store state.SimpleDB, id uint64, depositor sdk.Actor) *Deposit {
+	b := store.Get(DepositKey(id, depositor))
+	if b == nil {
+		return nil
+	}
+
+	deposit := new(Deposit)
+	err := wire.ReadBinaryBytes(b, deposit)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+	return deposit
+}
+func loadDeposits(store state.SimpleDB, id uint64) (deposits []*Deposit) {
+	start := appendID(DepositKeyPrefix, id)
+	for _, model := range store.List(start, appendID(DepositKeyPrefix, id+1), 0) {
+		deposit := new(Deposit)
+		err := wire.ReadBinaryBytes(model.Value, deposit)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		deposits = append(deposits, deposit)
+	}
+	return
+}
+func saveDeposit(store state.SimpleDB, id uint64, deposit *Deposit) {
+	store.Set(DepositKey(id, deposit.Depositor), wire.BinaryBytes(*deposit))
+}
+func removeDeposit(store state.SimpleDB, id uint64, deposit *Deposit) {
+	store.Remove(DepositKey(id, deposit.Depositor))
+}
+
+// load/save the votes on a proposal
+func loadVotes(store state.SimpleDB, id uint64) (votes []*Vote) {
+	start := appendID(VoteKeyPrefix, id)
+	for _, model := range store.List(start, appendID(VoteKeyPrefix, id+1), 0) {
+		vote := new(Vote)
+		err := wire.ReadBinaryBytes(model.Value, vote)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		votes = append(votes, vote)
+	}
+	return
+}
+func saveVote(store state.SimpleDB, id uint64, vote *Vote) {
+	store.Set(VoteKey(id, vote.Voter), wire.BinaryBytes(*vote))
+}
+
+// add/remove a proposal to the queue at the end of its current period, and
+// load the ids of the proposals whose period ends at or before the height
+// with the keys of their queue entries
+func pushProposalQueue(store state.SimpleDB, proposal *Proposal) {
+	store.Set(proposalQueueKey(proposal.endHeight(), proposal.ID), wire.BinaryBytes(proposal.ID))
+}
+func removeProposalQueue(store state.SimpleDB, proposal *Proposal) {
+	store.Remove(proposalQueueKey(proposal.endHeight(), proposal.ID))
+}
+func loadProposalQueue(store state.SimpleDB, height uint64) (ids []uint64, keys [][]byte) {
+	start := appendID(ProposalQueuePrefix, 0)
+	end := appendID(ProposalQueuePrefix, height+1)
+	for _, model := range store.List(start, end, 0) {
+		var id uint64
+		err := wire.ReadBinaryBytes(model.Value, &id)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		ids = append(ids, id)
+		keys = append(keys, model.Key)
+	}
+	return
+}
+
+// load/save the coins of the deposit account set by the genesis
+func loadGenesisCoins(store state.SimpleDB) (coins coin.Coins) {
+	b := store.Get(GenesisCoinsKey)
+	if b == nil {
+		return
+	}
+
+	err := wire.ReadBinaryBytes(b, &coins)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+	return
+}
+func saveGenesisCoins(store state.SimpleDB, coins coin.Coins) {
+	store.Set(GenesisCoinsKey, wire.BinaryBytes(coins))
+}
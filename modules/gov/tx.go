@@ -0,0 +1,139 @@
+package gov
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+// Tx
+//--------------------------------------------------------------------------------
+
+// register the tx type with its validation logic
+// make sure to use the name of the handler as the prefix in the tx type,
+// so it gets routed properly
+const (
+	ByteTxSubmitProposal = 0x60
+	ByteTxDeposit        = 0x61
+	ByteTxVote           = 0x62
+	TypeTxSubmitProposal = govModuleName + "/submitProposal"
+	TypeTxDeposit        = govModuleName + "/deposit"
+	TypeTxVote           = govModuleName + "/vote"
+)
+
+func init() {
+	sdk.TxMapper.RegisterImplementation(TxSubmitProposal{}, TypeTxSubmitProposal, ByteTxSubmitProposal)
+	sdk.TxMapper.RegisterImplementation(TxDeposit{}, TypeTxDeposit, ByteTxDeposit)
+	sdk.TxMapper.RegisterImplementation(TxVote{}, TypeTxVote, ByteTxVote)
+}
+
+// Verify interface at compile time
+var _, _, _ sdk.TxInner = &TxSubmitProposal{}, &TxDeposit{}, &TxVote{}
+
+// TxSubmitProposal - struct for proposing changes of the stake params, with
+// the first deposit on the proposal. Without changes it is a text proposal.
+type TxSubmitProposal struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Changes     []ParamChange `json:"changes"`
+	Deposit     coin.Coin     `json:"deposit"`
+}
+
+// NewTxSubmitProposal - new TxSubmitProposal
+func NewTxSubmitProposal(title, description string,
+	changes []ParamChange, deposit coin.Coin) sdk.Tx {
+
+	return TxSubmitProposal{
+		Title:       title,
+		Description: description,
+		Changes:     changes,
+		Deposit:     deposit,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxSubmitProposal) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check for a title, valid param changes and a valid deposit
+func (tx TxSubmitProposal) ValidateBasic() error {
+	if len(tx.Title) == 0 {
+		return errEmptyTitle
+	}
+	// each change is checked on its own, the params they result in depend on
+	// the stake params and are checked by CheckTx
+	for _, change := range tx.Changes {
+		err := stake.ValidateParam(change.Key, change.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return validateBasic(tx.Deposit)
+}
+
+// TxDeposit - struct for adding to the deposit of a proposal
+type TxDeposit struct {
+	ProposalID uint64    `json:"proposal_id"`
+	Amount     coin.Coin `json:"amount"`
+}
+
+// NewTxDeposit - new TxDeposit
+func NewTxDeposit(proposalID uint64, amount coin.Coin) sdk.Tx {
+	return TxDeposit{
+		ProposalID: proposalID,
+		Amount:     amount,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxDeposit) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check for valid coins
+func (tx TxDeposit) ValidateBasic() error {
+	return validateBasic(tx.Amount)
+}
+
+// TxVote - struct for voting on a proposal, weighted by the coins the sender
+// has bonded when the voting period ends
+type TxVote struct {
+	ProposalID uint64 `json:"proposal_id"`
+	Option     string `json:"option"`
+}
+
+// NewTxVote - new TxVote
+func NewTxVote(proposalID uint64, option string) sdk.Tx {
+	return TxVote{
+		ProposalID: proposalID,
+		Option:     option,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxVote) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check for a valid option
+func (tx TxVote) ValidateBasic() error {
+	if !validVoteOption(tx.Option) {
+		return errBadVoteOption
+	}
+	return nil
+}
+
+func validateBasic(amount coin.Coin) error {
+	coins := coin.Coins{amount}
+	if !coins.IsValid() {
+		return coin.ErrInvalidCoins()
+	}
+	if !coins.IsPositive() {
+		return fmt.Errorf("Amount must be > 0")
+	}
+	return nil
+}
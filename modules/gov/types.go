@@ -0,0 +1,157 @@
+package gov
+
+import (
+	"math/big"
+
+	"github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+// Params defines the settings of the governance procedure
+type Params struct {
+	MinDeposit    coin.Coin `json:"min_deposit"`    // deposit needed for a proposal to be voted on
+	DepositPeriod uint64    `json:"deposit_period"` // number of blocks to reach the min deposit
+	VotingPeriod  uint64    `json:"voting_period"`  // number of blocks a proposal is voted on
+
+	// fraction of the bonded coins which must vote for the tally to be valid
+	Quorum stake.Fraction `json:"quorum"`
+	// fraction of the voting power of the votes, abstain excluded, which must
+	// vote yes for a proposal to pass
+	Threshold stake.Fraction `json:"threshold"`
+	// fraction of the voting power of the votes which must veto a proposal for
+	// it to be rejected and its deposits burned
+	VetoThreshold stake.Fraction `json:"veto_threshold"`
+}
+
+func defaultParams() Params {
+	return Params{
+		MinDeposit:    coin.Coin{"fermion", 100},
+		DepositPeriod: 17280,  // one day of 5 second blocks
+		VotingPeriod:  120960, // one week of 5 second blocks
+		Quorum:        stake.NewFraction(1, 3),
+		Threshold:     stake.NewFraction(1, 2),
+		VetoThreshold: stake.NewFraction(1, 3),
+	}
+}
+
+//--------------------------------------------------------------------------------
+
+// ParamChange - a new value for the stake param with the genesis key, the value
+// is validated as the genesis option would be
+type ParamChange struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// the keys and values of the changes, in the form the stake module takes them
+func paramKeyValues(changes []ParamChange) (keys, values []string) {
+	for _, change := range changes {
+		keys = append(keys, change.Key)
+		values = append(values, change.Value)
+	}
+	return
+}
+
+// nolint - the statuses a proposal goes through
+const (
+	StatusDepositPeriod = "deposit_period" // waiting for the min deposit
+	StatusVotingPeriod  = "voting_period"  // being voted on
+	StatusPassed        = "passed"         // the changes have been applied
+	StatusRejected      = "rejected"       // the vote did not pass or reach the quorum
+	StatusVetoed        = "vetoed"         // the vote was vetoed, the deposits are burned
+	StatusDropped       = "dropped"        // the min deposit was not reached in time
+)
+
+// Proposal - a proposal voted on by the bonded coins. Once the deposits reach
+// the min deposit it is voted on for the voting period, weighted by the coins
+// each voter has bonded, and its changes of the stake params are applied if it
+// passes. A proposal without changes is a text proposal, which has no effect
+// on the state.
+type Proposal struct {
+	ID          uint64        `json:"id"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Changes     []ParamChange `json:"changes"`
+	Proposer    sdk.Actor     `json:"proposer"`
+	Status      string        `json:"status"`
+
+	SubmitHeight     uint64    `json:"submit_height"`
+	DepositEndHeight uint64    `json:"deposit_end_height"` // height the deposit period ends at
+	VotingEndHeight  uint64    `json:"voting_end_height"`  // height the voting period ends at
+	TotalDeposit     coin.Coin `json:"total_deposit"`
+
+	Tally TallyResult `json:"tally"` // set once the voting period has ended
+}
+
+// the height the current period of the proposal ends at
+func (p Proposal) endHeight() uint64 {
+	if p.Status == StatusDepositPeriod {
+		return p.DepositEndHeight
+	}
+	return p.VotingEndHeight
+}
+
+// Deposit - coins deposited on a proposal, held until the proposal ends
+type Deposit struct {
+	Depositor sdk.Actor `json:"depositor"`
+	Amount    coin.Coin `json:"amount"`
+}
+
+// nolint - the options of a vote
+const (
+	OptionYes     = "yes"
+	OptionNo      = "no"
+	OptionAbstain = "abstain" // counts towards the quorum only
+	OptionVeto    = "veto"    // a no, which burns the deposits past the veto threshold
+)
+
+func validVoteOption(option string) bool {
+	switch option {
+	case OptionYes, OptionNo, OptionAbstain, OptionVeto:
+		return true
+	}
+	return false
+}
+
+// Vote - the vote of an account on a proposal, a later vote replaces it
+type Vote struct {
+	Voter  sdk.Actor `json:"voter"`
+	Option string    `json:"option"`
+}
+
+// TallyResult - the voting power of each option, and the coins bonded when
+// the votes were tallied
+type TallyResult struct {
+	Yes     uint64 `json:"yes"`
+	No      uint64 `json:"no"`
+	Abstain uint64 `json:"abstain"`
+	Veto    uint64 `json:"veto"`
+
+	TotalBonded uint64 `json:"total_bonded"`
+}
+
+// the status of a proposal at the end of its voting period
+func (t TallyResult) status(params Params) string {
+	voted := t.Yes + t.No + t.Abstain + t.Veto
+	switch {
+	case voted == 0 || !moreThan(voted, t.TotalBonded, params.Quorum, true):
+		return StatusRejected
+	case moreThan(t.Veto, voted, params.VetoThreshold, false):
+		return StatusVetoed
+	case moreThan(t.Yes, t.Yes+t.No+t.Veto, params.Threshold, false):
+		return StatusPassed
+	}
+	return StatusRejected
+}
+
+// whether part is more than (or equal to) the fraction of total
+func moreThan(part, total uint64, fraction stake.Fraction, orEqual bool) bool {
+	p := new(big.Int).Mul(new(big.Int).SetUint64(part), big.NewInt(fraction.Denom))
+	min := new(big.Int).Mul(new(big.Int).SetUint64(total), big.NewInt(fraction.Num))
+	if orEqual {
+		return p.Cmp(min) >= 0
+	}
+	return p.Cmp(min) > 0
+}
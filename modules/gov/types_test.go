@@ -0,0 +1,44 @@
+package gov
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+func TestTallyStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	params := defaultParams()
+	twoThirds := params
+	twoThirds.Threshold = stake.NewFraction(2, 3)
+
+	testCases := []struct {
+		tally  TallyResult
+		params Params
+		status string
+	}{
+		{TallyResult{Yes: 51, No: 49, TotalBonded: 100}, params, StatusPassed},
+		{TallyResult{Yes: 50, No: 50, TotalBonded: 100}, params, StatusRejected},
+		{TallyResult{TotalBonded: 100}, params, StatusRejected},
+		{TallyResult{Abstain: 100, TotalBonded: 100}, params, StatusRejected},
+		{TallyResult{Yes: 66, No: 34, TotalBonded: 100}, twoThirds, StatusRejected},
+		{TallyResult{Yes: 67, No: 33, TotalBonded: 100}, twoThirds, StatusPassed},
+
+		// abstain counts towards the quorum but not the threshold
+		{TallyResult{Yes: 2, No: 1, Abstain: 97, TotalBonded: 100}, params, StatusPassed},
+		{TallyResult{Yes: 10, No: 1, TotalBonded: 100}, params, StatusRejected},
+		{TallyResult{Yes: 1, TotalBonded: 3}, params, StatusPassed},
+
+		// a veto is a no, past the veto threshold the proposal is vetoed
+		{TallyResult{Yes: 60, Veto: 40, TotalBonded: 100}, params, StatusVetoed},
+		{TallyResult{Yes: 67, Veto: 33, TotalBonded: 100}, params, StatusPassed},
+		{TallyResult{Yes: 40, No: 30, Veto: 30, TotalBonded: 100}, params, StatusRejected},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(tc.status, tc.tally.status(tc.params), "%v", tc.tally)
+	}
+}
@@ -1,11 +1,17 @@
 package commands
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	abci "github.com/tendermint/abci/types"
+	wire "github.com/tendermint/go-wire"
+
 	"github.com/cosmos/gaia/modules/stake"
 
+	sdk "github.com/cosmos/cosmos-sdk"
 	"github.com/cosmos/cosmos-sdk/client/commands"
 	"github.com/cosmos/cosmos-sdk/client/commands/query"
 	"github.com/cosmos/cosmos-sdk/stack"
@@ -15,26 +21,153 @@ import (
 var (
 	CmdQueryValidators = &cobra.Command{
 		Use:   "validators",
-		Short: "Query for the validator set",
+		Short: "Query for the bonds of all the validator candidates",
 		RunE:  cmdQueryValidators,
 	}
-	// TODO individual validators
-	//CmdQueryValidator = &cobra.Command{
-	//Use:   "validator",
-	//Short: "Query a validator account",
-	//RunE:  cmdQueryValidator,
-	//}
+	CmdQueryValidatorSet = &cobra.Command{
+		Use:   "validator-set",
+		Short: "Query for the validator set",
+		RunE:  cmdQueryValidatorSet,
+	}
+	CmdQueryNextValidators = &cobra.Command{
+		Use:   "next-validators",
+		Short: "Query for the validator set applied at the end of the epoch",
+		RunE:  cmdQueryNextValidators,
+	}
+	CmdQueryProvisions = &cobra.Command{
+		Use:   "provisions",
+		Short: "Query for the current inflation and bonded ratio",
+		RunE:  cmdQueryProvisions,
+	}
+	CmdQueryParams = &cobra.Command{
+		Use:   "stake-params",
+		Short: "Query for the staking parameters",
+		RunE:  cmdQueryParams,
+	}
+	CmdQueryValidator = &cobra.Command{
+		Use:   "validator [address]",
+		Short: "Query the bond of a validator, by the address which declared it or by --pubkey",
+		RunE:  cmdQueryValidator,
+	}
 )
 
+func init() {
+	CmdQueryValidator.Flags().String(FlagPubKey, "", "PubKey of the Validator")
+}
+
+// the bonds are listed by the senders they are stored for, each bond is
+// queried at the height of the list
 func cmdQueryValidators(cmd *cobra.Command, args []string) error {
-	var bonds stake.ValidatorBonds
+	var senders []sdk.Actor
 
 	prove := !viper.GetBool(commands.FlagTrustNode)
-	key := stack.PrefixedKey(stake.Name(), stake.BondKey)
-	h, err := query.GetParsed(key, &bonds, query.GetHeight(), prove)
+	key := stack.PrefixedKey(stake.Name(), stake.ValidatorListKey)
+	h, err := query.GetParsed(key, &senders, query.GetHeight(), prove)
 	if err != nil {
 		return err
 	}
 
+	bonds := stake.ValidatorBonds{}
+	for _, sender := range senders {
+		var bond stake.ValidatorBond
+		key := stack.PrefixedKey(stake.Name(), stake.ValidatorKey(sender))
+		_, err := query.GetParsed(key, &bond, int(h), prove)
+		if err != nil {
+			return err
+		}
+		bonds = append(bonds, &bond)
+	}
+
 	return query.OutputProof(bonds, h)
 }
+
+func cmdQueryValidatorSet(cmd *cobra.Command, args []string) error {
+	return queryValidatorSet(stake.ValidatorSetKey)
+}
+
+func cmdQueryNextValidators(cmd *cobra.Command, args []string) error {
+	return queryValidatorSet(stake.NextValidatorSetKey)
+}
+
+func queryValidatorSet(setKey []byte) error {
+	var validators []*abci.Validator
+
+	prove := !viper.GetBool(commands.FlagTrustNode)
+	key := stack.PrefixedKey(stake.Name(), setKey)
+	h, err := query.GetParsed(key, &validators, query.GetHeight(), prove)
+	if err != nil {
+		return err
+	}
+
+	return query.OutputProof(validators, h)
+}
+
+func cmdQueryValidator(cmd *cobra.Command, args []string) error {
+	var bondKey []byte
+
+	prove := !viper.GetBool(commands.FlagTrustNode)
+	height := query.GetHeight()
+	pubkeyStr := viper.GetString(FlagPubKey)
+	switch {
+	case len(pubkeyStr) > 0 && len(args) == 0:
+		pubkey, err := getPubKey(pubkeyStr)
+		if err != nil {
+			return err
+		}
+
+		// the index by pubkey holds the key of the bond, the bond is
+		// queried at the same height
+		key := stack.PrefixedKey(stake.Name(), stake.ValidatorPubKeyKey(wire.BinaryBytes(pubkey)))
+		value, h, err := query.Get(key, height, prove)
+		if err != nil {
+			return err
+		}
+		if len(value) == 0 {
+			return fmt.Errorf("no validator with the pubkey %X", pubkey.Bytes())
+		}
+		bondKey, height = value, int(h)
+	case len(pubkeyStr) == 0 && len(args) == 1:
+		sender, err := commands.ParseActor(args[0])
+		if err != nil {
+			return err
+		}
+		bondKey = stake.ValidatorKey(sender)
+	default:
+		return fmt.Errorf("must provide either the address of a validator or --pubkey")
+	}
+
+	var bond stake.ValidatorBond
+	key := stack.PrefixedKey(stake.Name(), bondKey)
+	h, err := query.GetParsed(key, &bond, height, prove)
+	if err != nil {
+		return err
+	}
+
+	return query.OutputProof(bond, h)
+}
+
+func cmdQueryProvisions(cmd *cobra.Command, args []string) error {
+	var provisions stake.Provisions
+
+	prove := !viper.GetBool(commands.FlagTrustNode)
+	key := stack.PrefixedKey(stake.Name(), stake.ProvisionsKey)
+	h, err := query.GetParsed(key, &provisions, query.GetHeight(), prove)
+	if err != nil {
+		return err
+	}
+
+	return query.OutputProof(provisions, h)
+}
+
+func cmdQueryParams(cmd *cobra.Command, args []string) error {
+	var params stake.Params
+
+	prove := !viper.GetBool(commands.FlagTrustNode)
+	key := stack.PrefixedKey(stake.Name(), stake.ParamKey)
+	h, err := query.GetParsed(key, &params, query.GetHeight(), prove)
+	if err != nil {
+		return err
+	}
+
+	return query.OutputProof(params, h)
+}
@@ -2,7 +2,10 @@ package commands
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"strings"
 
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -11,8 +14,10 @@ import (
 	crypto "github.com/tendermint/go-crypto"
 	wire "github.com/tendermint/go-wire"
 
+	"github.com/cosmos/cosmos-sdk/client/commands"
 	"github.com/cosmos/cosmos-sdk/client/commands/keys"
 	txcmd "github.com/cosmos/cosmos-sdk/client/commands/txs"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
 	"github.com/cosmos/cosmos-sdk/modules/coin"
 
 	"github.com/cosmos/gaia/modules/stake"
@@ -20,15 +25,38 @@ import (
 
 // nolint
 const (
-	FlagAmount = "amount"
-	FlagPubKey = "pubkey"
+	FlagAmount        = "amount"
+	FlagPubKey        = "pubkey"
+	FlagValidatorFile = "validator-file"
+
+	FlagPubKeyFrom = "pubkey-from"
+	FlagPubKeyTo   = "pubkey-to"
+
+	FlagCommission          = "commission"
+	FlagCommissionMax       = "commission-max"
+	FlagCommissionMaxChange = "commission-max-change"
+
+	FlagMoniker  = "moniker"
+	FlagIdentity = "keybase-sig"
+	FlagWebsite  = "website"
+	FlagDetails  = "details"
 )
 
 // nolint
 var (
+	CmdDeclareCandidacy = &cobra.Command{
+		Use:   "declare-candidacy",
+		Short: "create a new validator candidate, bonding its first coins",
+		RunE:  cmdDeclareCandidacy,
+	}
+	CmdEditCandidacy = &cobra.Command{
+		Use:   "edit-candidacy",
+		Short: "edit the description of your validator candidate",
+		RunE:  cmdEditCandidacy,
+	}
 	CmdBond = &cobra.Command{
 		Use:   "bond",
-		Short: "bond coins to your validator bond account",
+		Short: "bond more coins to your validator candidate",
 		RunE:  cmdBond,
 	}
 	CmdUnbond = &cobra.Command{
@@ -36,6 +64,31 @@ var (
 		Short: "unbond coins from your validator bond account",
 		RunE:  cmdUnbond,
 	}
+	CmdDelegate = &cobra.Command{
+		Use:   "delegate",
+		Short: "delegate coins to an existing validator",
+		RunE:  cmdDelegate,
+	}
+	CmdUnbondDelegation = &cobra.Command{
+		Use:   "unbond-delegation",
+		Short: "unbond coins delegated to a validator",
+		RunE:  cmdUnbondDelegation,
+	}
+	CmdRedelegate = &cobra.Command{
+		Use:   "redelegate",
+		Short: "move delegated coins from one validator to another without unbonding",
+		RunE:  cmdRedelegate,
+	}
+	CmdEditValidator = &cobra.Command{
+		Use:   "edit-validator",
+		Short: "change the commission rate of your validator",
+		RunE:  cmdEditValidator,
+	}
+	CmdUnjail = &cobra.Command{
+		Use:   "unjail",
+		Short: "return your validator to the validator set after being jailed for downtime",
+		RunE:  cmdUnjail,
+	}
 )
 
 func init() {
@@ -44,8 +97,75 @@ func init() {
 	fsDelegation.String(FlagAmount, "1atom", "Amount of Atoms")
 	fsDelegation.String(FlagPubKey, "", "PubKey of the Validator")
 
+	fsValidator := flag.NewFlagSet("", flag.ContinueOnError)
+	fsValidator.String(FlagValidatorFile, "", "priv_validator.json file of the validator,"+
+		" to use its pubkey and sign a proof that you control it, or a file with just the pub_key")
+
+	fsCommission := flag.NewFlagSet("", flag.ContinueOnError)
+	fsCommission.String(FlagCommission, "0", "Commission rate kept from the rewards")
+	fsCommission.String(FlagCommissionMax, "0", "Maximum commission rate, set once when the validator is created")
+	fsCommission.String(FlagCommissionMaxChange, "0", "Maximum change of the commission rate per day, set once when the validator is created")
+
+	fsDescription := flag.NewFlagSet("", flag.ContinueOnError)
+	fsDescription.String(FlagMoniker, "", "Validator name")
+	fsDescription.String(FlagIdentity, "", "Optional keybase signature")
+	fsDescription.String(FlagWebsite, "", "Optional website")
+	fsDescription.String(FlagDetails, "", "Optional details")
+
+	CmdDeclareCandidacy.Flags().AddFlagSet(fsDelegation)
+	CmdDeclareCandidacy.Flags().AddFlagSet(fsValidator)
+	CmdDeclareCandidacy.Flags().AddFlagSet(fsDescription)
+	CmdDeclareCandidacy.Flags().AddFlagSet(fsCommission)
+	CmdEditCandidacy.Flags().AddFlagSet(fsDescription)
 	CmdBond.Flags().AddFlagSet(fsDelegation)
+	CmdBond.Flags().AddFlagSet(fsValidator)
 	CmdUnbond.Flags().AddFlagSet(fsDelegation)
+	CmdDelegate.Flags().AddFlagSet(fsDelegation)
+	CmdUnbondDelegation.Flags().AddFlagSet(fsDelegation)
+	CmdRedelegate.Flags().String(FlagAmount, "1atom", "Amount of Atoms")
+	CmdRedelegate.Flags().String(FlagPubKeyFrom, "", "PubKey of the Validator to move the coins from")
+	CmdRedelegate.Flags().String(FlagPubKeyTo, "", "PubKey of the Validator to move the coins to")
+	CmdEditValidator.Flags().String(FlagCommission, "0", "New commission rate")
+}
+
+func cmdDeclareCandidacy(cmd *cobra.Command, args []string) error {
+	amount, err := coin.ParseCoin(viper.GetString(FlagAmount))
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := getValidatorPubKey()
+	if err != nil {
+		return err
+	}
+
+	description := getDescription()
+	if len(description.Moniker) == 0 {
+		return fmt.Errorf("must use --moniker flag")
+	}
+
+	commission, err := getCommission()
+	if err != nil {
+		return err
+	}
+
+	signature, err := getPossessionSignature(pubkey)
+	if err != nil {
+		return err
+	}
+	if len(signature) == 0 {
+		return fmt.Errorf("must use --validator-file flag with the private key of the validator," +
+			" to prove the possession of its pubkey")
+	}
+
+	tx := stake.NewTxDeclareCandidacy(amount, wire.BinaryBytes(pubkey), signature,
+		description, commission)
+	return txcmd.DoTx(tx)
+}
+
+func cmdEditCandidacy(cmd *cobra.Command, args []string) error {
+	tx := stake.NewTxEditCandidacy(getDescription())
+	return txcmd.DoTx(tx)
 }
 
 func cmdBond(cmd *cobra.Command, args []string) error {
@@ -54,35 +174,23 @@ func cmdBond(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	var pubkey crypto.PubKey
-	pubkeyStr := viper.GetString(FlagPubKey)
-	if len(pubkeyStr) != 0 {
-		pkBytes, err := hex.DecodeString(pubkeyStr)
-		if err != nil {
-			return err
-		}
+	pubkey, err := getValidatorPubKey()
+	if err != nil {
+		return err
+	}
 
-		if len(pkBytes) != 32 { //if len(pubkeyStr) != 64 {
-			return fmt.Errorf("pubkey must be hex encoded string which is 64 characters long")
-		}
-		var pkEd crypto.PubKeyEd25519
-		copy(pkEd[:], pkBytes[:])
-		pubkey = pkEd.Wrap()
-
-	} else { // if pubkey flag is not used get the pubkey of the signer
-		name := viper.GetString(txcmd.FlagName)
-		if len(name) == 0 {
-			return fmt.Errorf("must use --name flag")
-		}
+	// without a pubkey the coins are bonded to the sender's own candidate
+	var pubkeyBytes []byte
+	if !pubkey.Empty() {
+		pubkeyBytes = wire.BinaryBytes(pubkey)
+	}
 
-		info, err := keys.GetKeyManager().Get(name)
-		if err != nil {
-			return err
-		}
-		pubkey = info.PubKey
+	signature, err := getPossessionSignature(pubkey)
+	if err != nil {
+		return err
 	}
 
-	tx := stake.NewTxBond(amount, wire.BinaryBytes(pubkey))
+	tx := stake.NewTxBond(amount, pubkeyBytes, signature)
 	return txcmd.DoTx(tx)
 }
 
@@ -95,3 +203,225 @@ func cmdUnbond(cmd *cobra.Command, args []string) error {
 	tx := stake.NewTxUnbond(amount)
 	return txcmd.DoTx(tx)
 }
+
+func cmdDelegate(cmd *cobra.Command, args []string) error {
+	amount, err := coin.ParseCoin(viper.GetString(FlagAmount))
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := getPubKey(viper.GetString(FlagPubKey))
+	if err != nil {
+		return err
+	}
+
+	tx := stake.NewTxDelegate(amount, wire.BinaryBytes(pubkey))
+	return txcmd.DoTx(tx)
+}
+
+func cmdUnbondDelegation(cmd *cobra.Command, args []string) error {
+	amount, err := coin.ParseCoin(viper.GetString(FlagAmount))
+	if err != nil {
+		return err
+	}
+
+	pubkey, err := getPubKey(viper.GetString(FlagPubKey))
+	if err != nil {
+		return err
+	}
+
+	tx := stake.NewTxUnbondDelegation(amount, wire.BinaryBytes(pubkey))
+	return txcmd.DoTx(tx)
+}
+
+func cmdRedelegate(cmd *cobra.Command, args []string) error {
+	amount, err := coin.ParseCoin(viper.GetString(FlagAmount))
+	if err != nil {
+		return err
+	}
+
+	pubkeyFrom, err := getPubKey(viper.GetString(FlagPubKeyFrom))
+	if err != nil {
+		return err
+	}
+	pubkeyTo, err := getPubKey(viper.GetString(FlagPubKeyTo))
+	if err != nil {
+		return err
+	}
+
+	tx := stake.NewTxRedelegate(amount, wire.BinaryBytes(pubkeyFrom), wire.BinaryBytes(pubkeyTo))
+	return txcmd.DoTx(tx)
+}
+
+func cmdEditValidator(cmd *cobra.Command, args []string) error {
+	rate, err := stake.ParseFraction(viper.GetString(FlagCommission))
+	if err != nil {
+		return err
+	}
+
+	tx := stake.NewTxEditValidator(rate)
+	return txcmd.DoTx(tx)
+}
+
+func cmdUnjail(cmd *cobra.Command, args []string) error {
+	tx := stake.NewTxUnjail()
+	return txcmd.DoTx(tx)
+}
+
+// the pubkey from the --pubkey flag, or the pubkey of the --validator-file,
+// empty if neither is used
+func getValidatorPubKey() (pubkey crypto.PubKey, err error) {
+	pubkeyStr := viper.GetString(FlagPubKey)
+	if len(pubkeyStr) != 0 {
+		return getPubKey(pubkeyStr)
+	}
+
+	file := viper.GetString(FlagValidatorFile)
+	if len(file) != 0 {
+		privVal, err := loadPrivValidator(file)
+		if err != nil {
+			return pubkey, err
+		}
+		return privVal.PubKey, nil
+	}
+
+	return pubkey, nil
+}
+
+// the pubkey of the key the tx is signed with, from the --name flag
+func getSigner() (pubkey crypto.PubKey, err error) {
+	name := viper.GetString(txcmd.FlagName)
+	if len(name) == 0 {
+		err = fmt.Errorf("must use --name flag")
+		return
+	}
+
+	info, err := keys.GetKeyManager().Get(name)
+	if err != nil {
+		return
+	}
+	return info.PubKey, nil
+}
+
+// privValidator - the keys of a priv_validator.json file, the private key is
+// empty for a file with just the pub_key
+type privValidator struct {
+	PubKey  crypto.PubKey  `json:"pub_key"`
+	PrivKey crypto.PrivKey `json:"priv_key"`
+}
+
+func loadPrivValidator(file string) (privVal privValidator, err error) {
+	bz, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(bz, &privVal)
+	if err == nil && privVal.PubKey.Empty() {
+		// the file may hold just the pub_key
+		err = json.Unmarshal(bz, &privVal.PubKey)
+	}
+	if err != nil {
+		return privVal, fmt.Errorf("cannot read validator file %v: %v", file, err)
+	}
+	if privVal.PubKey.Empty() {
+		return privVal, fmt.Errorf("validator file %v has no pub_key", file)
+	}
+	return
+}
+
+// sign the address of the signer with the private key of the --validator-file,
+// proving the signer controls the validator pubkey. There is no signature if
+// the flag isn't used, or the file has no private key.
+func getPossessionSignature(pubkey crypto.PubKey) ([]byte, error) {
+	file := viper.GetString(FlagValidatorFile)
+	if len(file) == 0 {
+		return nil, nil
+	}
+
+	privVal, err := loadPrivValidator(file)
+	if err != nil {
+		return nil, err
+	}
+	if privVal.PrivKey.Empty() {
+		return nil, nil
+	}
+	if !privVal.PubKey.Equals(pubkey) {
+		return nil, fmt.Errorf("validator file %v is not for the pubkey %X", file, pubkey.Bytes())
+	}
+
+	signer, err := getSigner()
+	if err != nil {
+		return nil, err
+	}
+	sender := auth.SigPerm(signer.Address())
+	signBytes := stake.PossessionSignBytes(commands.GetChainID(), sender)
+	return privVal.PrivKey.Sign(signBytes).Bytes(), nil
+}
+
+func getDescription() stake.Description {
+	return stake.NewDescription(
+		viper.GetString(FlagMoniker),
+		viper.GetString(FlagIdentity),
+		viper.GetString(FlagWebsite),
+		viper.GetString(FlagDetails),
+	)
+}
+
+// parse the commission rates, as fractions or decimals
+func getCommission() (commission stake.Commission, err error) {
+	rate, err := stake.ParseFraction(viper.GetString(FlagCommission))
+	if err != nil {
+		return
+	}
+	max, err := stake.ParseFraction(viper.GetString(FlagCommissionMax))
+	if err != nil {
+		return
+	}
+	maxChange, err := stake.ParseFraction(viper.GetString(FlagCommissionMaxChange))
+	if err != nil {
+		return
+	}
+	return stake.NewCommission(rate, max, maxChange), nil
+}
+
+// parse the pubkey of a validator, either in the JSON form tendermint prints
+// from /validators, {"type":"ed25519","data":"<hex>"}, or hex encoded. The hex
+// is of the 32 bytes of an ed25519 pubkey, as found under "pub_key" in the
+// priv_validator.json, or of the go-wire bytes of any pubkey go-crypto can wrap.
+func getPubKey(pubkeyStr string) (pubkey crypto.PubKey, err error) {
+	pubkeyStr = strings.TrimSpace(pubkeyStr)
+	if len(pubkeyStr) == 0 {
+		err = fmt.Errorf("must use --pubkey flag")
+		return
+	}
+
+	if strings.HasPrefix(pubkeyStr, "{") {
+		err = json.Unmarshal([]byte(pubkeyStr), &pubkey)
+		if err != nil {
+			return pubkey, fmt.Errorf("cannot parse the JSON pubkey %v: %v", pubkeyStr, err)
+		}
+	} else {
+		var pkBytes []byte
+		pkBytes, err = hex.DecodeString(pubkeyStr)
+		if err != nil {
+			return
+		}
+
+		if len(pkBytes) == 32 {
+			var pkEd crypto.PubKeyEd25519
+			copy(pkEd[:], pkBytes[:])
+			return pkEd.Wrap(), nil
+		}
+		pubkey, err = crypto.PubKeyFromBytes(pkBytes)
+		if err != nil {
+			return pubkey, fmt.Errorf("pubkey must be the hex encoding of an ed25519 pubkey,"+
+				" 64 characters long, or of the go-wire bytes of a pubkey: %v", err)
+		}
+	}
+
+	if pubkey.Empty() {
+		err = fmt.Errorf("pubkey %v is empty", pubkeyStr)
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package stake
+
+import (
+	"bytes"
+	"sort"
+
+	abci "github.com/tendermint/abci/types"
+	crypto "github.com/tendermint/go-crypto"
+
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+// SigningInfo - the blocks missed by a validator within the sliding window of
+// the last SignedBlocksWindow blocks
+type SigningInfo struct {
+	PubKey              []byte `json:"pub_key"`
+	StartHeight         uint64 `json:"start_height"`          // height the tracking started at
+	IndexOffset         uint64 `json:"index_offset"`          // number of blocks tracked so far
+	MissedBlocks        []bool `json:"missed_blocks"`         // missed blocks, indexed modulo the window
+	MissedBlocksCounter uint64 `json:"missed_blocks_counter"` // number of missed blocks in the window
+}
+
+// NewSigningInfo - returns a new signing info with an empty window
+func NewSigningInfo(pubKey []byte, height, window uint64) *SigningInfo {
+	return &SigningInfo{
+		PubKey:              pubKey,
+		StartHeight:         height,
+		IndexOffset:         0,
+		MissedBlocks:        make([]bool, window),
+		MissedBlocksCounter: 0,
+	}
+}
+
+// record whether the validator missed the next block, the block falling out
+// of the window is forgotten
+func (info *SigningInfo) markBlock(missed bool) {
+	idx := info.IndexOffset % uint64(len(info.MissedBlocks))
+	if info.MissedBlocks[idx] {
+		info.MissedBlocksCounter--
+	}
+	if missed {
+		info.MissedBlocksCounter++
+	}
+	info.MissedBlocks[idx] = missed
+	info.IndexOffset++
+}
+
+// ProcessAbsentValidators - record which validators were absent from the last
+// commit, and jail the validators which signed less than MinSignedPerWindow of
+// the blocks in the window. Jailed validators are slashed by
+// SlashFractionDowntime, the coins being burned directly from the hold accounts
+// within coinStore, the store of the coin module.
+func ProcessAbsentValidators(store, coinStore state.SimpleDB,
+	height uint64, absent []int32) error {
+
+	return processAbsentValidators(store, storeChangeCoinsFn(coinStore), height, absent)
+}
+
+// separated for testing
+func processAbsentValidators(store state.SimpleDB, burnFn changeCoinsFn,
+	height uint64, absent []int32) error {
+
+	params := loadParams(store)
+	window := params.SignedBlocksWindow
+
+	// the absent validators are indexes into the validator set which signed
+	// the last block, as recorded by the previous call
+	signers := loadSigners(store)
+	isAbsent := make(map[string]bool)
+	for _, i := range absent {
+		if i >= 0 && int(i) < len(signers) {
+			isAbsent[string(signers[i])] = true
+		}
+	}
+
+	// nothing is tracked without a window
+	if window > 0 {
+		for _, pubKey := range signers {
+			err := handleSignature(store, burnFn, params, height, pubKey, isAbsent[string(pubKey)])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// the validator set is only updated after this, so it signs this block
+	saveSigners(store, signersByAddress(loadValidatorSet(store)))
+	return nil
+}
+
+// record whether the validator signed the block and jail it once it has missed
+// too many blocks in the window
+func handleSignature(store state.SimpleDB, burnFn changeCoinsFn, params Params,
+	height uint64, pubKey []byte, absent bool) error {
+
+	// the validator may have been removed or jailed since it signed
+	bond := loadValidatorBondByPubKey(store, pubKey)
+	if bond == nil || bond.Jailed || bond.Revoked {
+		return nil
+	}
+
+	window := params.SignedBlocksWindow
+	info := loadSigningInfo(store, pubKey)
+	if info == nil || uint64(len(info.MissedBlocks)) != window {
+		info = NewSigningInfo(pubKey, height, window)
+	}
+	info.markBlock(absent)
+
+	// only a full window is judged, so new validators get a chance to start
+	minSigned := params.MinSignedPerWindow
+	maxMissed := window - mulDiv(window, uint64(minSigned.Num), uint64(minSigned.Denom), true)
+	if info.IndexOffset < window || info.MissedBlocksCounter <= maxMissed {
+		saveSigningInfo(store, info)
+		return nil
+	}
+
+	err := slash(store, burnFn, pubKey, params.SlashFractionDowntime)
+	if err != nil {
+		return err
+	}
+
+	// the bond is reloaded as slashing has saved it
+	bond = loadValidatorBondByPubKey(store, pubKey)
+	bond.Jailed = true
+	bond.JailedUntil = height + params.DowntimeJailPeriod
+	saveValidatorBond(store, bond)
+	removeSigningInfo(store, pubKey)
+	return nil
+}
+
+// signersByAddress - the pubkeys of the validators in the order tendermint
+// indexes its validator set, sorted by the address of the pubkey. Pubkeys
+// which cannot be decoded are ordered by their bytes instead.
+func signersByAddress(validators []*abci.Validator) [][]byte {
+	type signer struct {
+		address, pubKey []byte
+	}
+
+	var signers []signer
+	for _, val := range validators {
+		if val == nil {
+			continue
+		}
+		address := val.PubKey
+		pk, err := crypto.PubKeyFromBytes(val.PubKey)
+		if err == nil && !pk.Empty() {
+			address = pk.Address()
+		}
+		signers = append(signers, signer{address, val.PubKey})
+	}
+
+	sort.Slice(signers, func(i, j int) bool {
+		return bytes.Compare(signers[i].address, signers[j].address) < 0
+	})
+
+	pubKeys := make([][]byte, len(signers))
+	for i, s := range signers {
+		pubKeys[i] = s.pubKey
+	}
+	return pubKeys
+}
@@ -0,0 +1,95 @@
+package stake
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+func TestSigningInfoWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	info := NewSigningInfo([]byte("pubkey"), 1, 3)
+	for _, missed := range []bool{true, true, false} {
+		info.markBlock(missed)
+	}
+	assert.Equal(uint64(2), info.MissedBlocksCounter)
+
+	// the oldest blocks fall out of the window
+	info.markBlock(false)
+	assert.Equal(uint64(1), info.MissedBlocksCounter)
+	info.markBlock(true)
+	assert.Equal(uint64(1), info.MissedBlocksCounter)
+	info.markBlock(false)
+	assert.Equal(uint64(1), info.MissedBlocksCounter)
+	assert.Equal(uint64(6), info.IndexOffset)
+}
+
+func TestProcessAbsentValidators(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(2)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{1000, 500}))
+	saveBonds(store, bonds)
+	saveValidatorSet(store, bonds.GetValidators(store))
+	present, absent := bonds[0], bonds[1]
+
+	params := defaultParams()
+	params.SignedBlocksWindow = 4
+	params.MinSignedPerWindow = NewFraction(1, 2)
+	params.DowntimeJailPeriod = 10
+	params.SlashFractionDowntime = NewFraction(1, 10)
+	saveParams(store, params)
+
+	// the first block has no previous signers to judge
+	require.Nil(processAbsentValidators(store, dummyChangeCoinsFn(accStore), 1, nil))
+	signers := loadSigners(store)
+	require.Equal(2, len(signers))
+	assert.Equal(present.PubKey, signers[0])
+	assert.Equal(absent.PubKey, signers[1])
+
+	// missing blocks is tolerated until a full window has been tracked
+	for height := uint64(2); height <= 4; height++ {
+		err := processAbsentValidators(store, dummyChangeCoinsFn(accStore), height, []int32{1})
+		require.Nil(err)
+	}
+	_, bond := LoadBonds(store).GetByPubKey(absent.PubKey)
+	assert.False(bond.Jailed)
+	assert.Equal(uint64(3), loadSigningInfo(store, absent.PubKey).MissedBlocksCounter)
+	assert.Equal(uint64(0), loadSigningInfo(store, present.PubKey).MissedBlocksCounter)
+
+	// missing more than half of the window jails and slashes the validator
+	require.Nil(processAbsentValidators(store, dummyChangeCoinsFn(accStore), 5, []int32{1}))
+	bonds = LoadBonds(store)
+	_, bond = bonds.GetByPubKey(absent.PubKey)
+	assert.True(bond.Jailed)
+	assert.Equal(uint64(15), bond.JailedUntil)
+	assert.Equal(uint64(450), bond.BondedCoins)
+	assert.Nil(loadSigningInfo(store, absent.PubKey))
+	_, bond = bonds.GetByPubKey(present.PubKey)
+	assert.False(bond.Jailed)
+	assert.Equal(uint64(1000), bond.BondedCoins)
+
+	// the jailed validator leaves the validator set
+	require.True(bonds.UpdateVotingPower(store))
+	_, bond = bonds.GetByPubKey(absent.PubKey)
+	assert.Equal(uint64(0), bond.VotingPower)
+
+	// it can only be unjailed by its sender once the jail period is over
+	sender := absent.Sender
+	assert.NotNil(checkTxUnjail(TxUnjail{}, sender, 10, store))
+	assert.Equal(errValidatorNotJailed, checkTxUnjail(TxUnjail{}, present.Sender, 15, store))
+	require.Nil(checkTxUnjail(TxUnjail{}, sender, 15, store))
+	require.True(runTxUnjail(store, sender, TxUnjail{}).IsOK())
+
+	bonds = LoadBonds(store)
+	_, bond = bonds.GetByPubKey(absent.PubKey)
+	assert.False(bond.Jailed)
+	require.True(bonds.UpdateVotingPower(store))
+	assert.Equal(uint64(450), bond.VotingPower)
+}
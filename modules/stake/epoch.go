@@ -0,0 +1,141 @@
+package stake
+
+import (
+	"sort"
+
+	abci "github.com/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+// UpdateValidatorSet - update the voting power of the validator bonds and
+// return the changes of the validator set for tendermint. The changes of power
+// accumulate over the epoch and the validator set of the next epoch, by the
+// voting power, is applied at its end, every EpochLength blocks. The applied
+// changes move at most max_power_change_per_block of the power of the set,
+// the rest is carried forward to the end of the following epochs. Jailed and
+// revoked validators leave the validator set right away.
+func UpdateValidatorSet(store state.SimpleDB, height uint64) (diff []*abci.Validator) {
+	bonds := LoadBonds(store)
+	bonds.UpdateVotingPower(store)
+	nextVals := bonds.GetValidators(store)
+	saveNextValidatorSet(store, nextVals)
+
+	startVals := loadValidatorSet(store)
+	newVals := removePunished(store, startVals)
+	if isEpochEnd(loadParams(store), height) {
+		newVals = limitPowerChange(store, startVals, nextVals)
+	}
+
+	diff = ValidatorsDiff(startVals, newVals, store)
+	if len(diff) > 0 {
+		saveValidatorSet(store, newVals)
+	}
+	return diff
+}
+
+// whether the epoch ends at the height
+func isEpochEnd(params Params, height uint64) bool {
+	return height%params.EpochLength == 0
+}
+
+// the validators of the set whose bond is neither jailed nor revoked
+func removePunished(store state.SimpleDB, validators []*abci.Validator) (kept []*abci.Validator) {
+	for _, val := range validators {
+		bond := loadValidatorBondByPubKey(store, val.PubKey)
+		if bond != nil && (bond.Jailed || bond.Revoked) {
+			continue
+		}
+		kept = append(kept, val)
+	}
+	return
+}
+
+// limitPowerChange - the validator set moved from the previous set towards
+// the next one, by at most max_power_change_per_block of the total power of
+// the previous set. Jailed and revoked validators leave right away, the
+// decreases of power come before the increases, and a new validator only
+// joins once there is room in the set. The first validator set has no limit.
+func limitPowerChange(store state.SimpleDB, previous, next []*abci.Validator) []*abci.Validator {
+	var total uint64
+	for _, val := range previous {
+		total += val.Power
+	}
+	if total == 0 {
+		return next
+	}
+
+	params := loadParams(store)
+	maxChange := params.MaxPowerChangePerBlock
+	budget := mulDiv(total, uint64(maxChange.Num), uint64(maxChange.Denom), false)
+	if budget == 0 {
+		budget = 1
+	}
+	spend := func(change uint64) uint64 {
+		if change > budget {
+			change = budget
+		}
+		budget -= change
+		return change
+	}
+
+	due := make(map[string]uint64, len(next))
+	for _, val := range next {
+		due[string(val.PubKey)] = val.Power
+	}
+	kept := removePunished(store, previous)
+	power := make(map[string]uint64, len(previous))
+	for _, val := range kept {
+		power[string(val.PubKey)] = val.Power
+	}
+	for _, val := range previous {
+		if _, ok := power[string(val.PubKey)]; !ok {
+			spend(val.Power)
+		}
+	}
+
+	numVals := 0
+	for _, val := range kept {
+		key := string(val.PubKey)
+		if due[key] < val.Power {
+			power[key] = val.Power - spend(val.Power-due[key])
+		}
+		if power[key] > 0 {
+			numVals++
+		}
+	}
+
+	for _, val := range next {
+		key := string(val.PubKey)
+		stored := power[key]
+		if val.Power <= stored {
+			continue
+		}
+		if stored == 0 && numVals >= params.MaxVals {
+			continue
+		}
+		power[key] = stored + spend(val.Power-stored)
+		if stored == 0 && power[key] > 0 {
+			numVals++
+		}
+	}
+
+	// the set is ordered by power, then as the next set, with the validators
+	// still leaving it last
+	var validators []*abci.Validator
+	added := make(map[string]bool, len(power))
+	for _, vals := range [][]*abci.Validator{next, kept} {
+		for _, val := range vals {
+			key := string(val.PubKey)
+			if power[key] == 0 || added[key] {
+				continue
+			}
+			added[key] = true
+			validators = append(validators, &abci.Validator{PubKey: val.PubKey, Power: power[key]})
+		}
+	}
+	sort.SliceStable(validators, func(i, j int) bool {
+		return validators[i].Power > validators[j].Power
+	})
+	return validators
+}
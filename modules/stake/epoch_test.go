@@ -0,0 +1,196 @@
+package stake
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+func TestUpdateValidatorSet(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	params := defaultParams()
+	params.EpochLength = 10
+	saveParams(store, params)
+	actors := newActors(3)
+	for _, bond := range bondsFromActors(actors, []int{10, 300, 123}) {
+		saveValidatorBond(store, bond)
+	}
+
+	// the first set is applied at the end of the epoch
+	assert.Empty(UpdateValidatorSet(store, 9))
+	assert.Empty(loadValidatorSet(store))
+	assert.Equal(3, len(loadNextValidatorSet(store)))
+	require.Equal(3, len(UpdateValidatorSet(store, 10)))
+	require.Equal(3, len(loadValidatorSet(store)))
+
+	// the changes of power accumulate over the epoch
+	bond := loadValidatorBond(store, actors[0])
+	bond.BondedTokens, bond.BondedCoins = 1000, 1000
+	saveValidatorBond(store, bond)
+	assert.Empty(UpdateValidatorSet(store, 11))
+	assert.Equal(uint64(10), powerOf(loadValidatorSet(store), bond.PubKey))
+	assert.Equal(uint64(1000), powerOf(loadNextValidatorSet(store), bond.PubKey))
+
+	// a jailed validator leaves the set right away
+	jailed := loadValidatorBond(store, actors[1])
+	jailed.Jailed = true
+	saveValidatorBond(store, jailed)
+	diff := UpdateValidatorSet(store, 12)
+	require.Equal(1, len(diff))
+	assert.Equal(jailed.PubKey, diff[0].PubKey)
+	assert.Equal(uint64(0), diff[0].Power)
+	assert.Equal(2, len(loadValidatorSet(store)))
+	assert.Equal(uint64(10), powerOf(loadValidatorSet(store), bond.PubKey))
+
+	// the next set is applied at the end of the epoch, the change of power
+	// accumulated over the epoch is limited to a third of the power of the set
+	assert.Empty(UpdateValidatorSet(store, 19))
+	diff = updateSetBlock(t, store, 20)
+	require.Equal(1, len(diff))
+	assert.Equal(bond.PubKey, diff[0].PubKey)
+	assert.Equal(uint64(54), diff[0].Power)
+	assert.Equal(uint64(1000), powerOf(loadNextValidatorSet(store), bond.PubKey))
+
+	// the rest is carried forward to the end of the next epochs
+	assert.Empty(UpdateValidatorSet(store, 29))
+	diff = updateSetBlock(t, store, 30)
+	require.Equal(1, len(diff))
+	assert.Equal(uint64(113), diff[0].Power)
+
+	// with an epoch of one block the set is updated every block
+	params.EpochLength = 1
+	saveParams(store, params)
+	bond = loadValidatorBond(store, actors[0])
+	bond.BondedTokens, bond.BondedCoins = 500, 500
+	saveValidatorBond(store, bond)
+	diff = updateSetBlock(t, store, 31)
+	require.Equal(1, len(diff))
+	assert.Equal(uint64(191), diff[0].Power)
+	for height := uint64(32); powerOf(loadValidatorSet(store), bond.PubKey) < 500; height++ {
+		require.True(height < 40, "the validator never reached its power")
+		require.NotEmpty(updateSetBlock(t, store, height))
+	}
+	assert.Equal(loadNextValidatorSet(store), loadValidatorSet(store))
+	assert.Empty(UpdateValidatorSet(store, 40))
+}
+
+// run UpdateValidatorSet for a block, checking that the emitted diff moves
+// the power of the validator set by at most a third of its total power
+func updateSetBlock(t *testing.T, store state.SimpleDB, height uint64) []*abci.Validator {
+	previous := loadValidatorSet(store)
+	var total uint64
+	for _, val := range previous {
+		total += val.Power
+	}
+
+	diff := UpdateValidatorSet(store, height)
+	var change uint64
+	for _, val := range diff {
+		before := powerOf(previous, val.PubKey)
+		if val.Power > before {
+			change += val.Power - before
+		} else {
+			change += before - val.Power
+		}
+	}
+	assert.True(t, change <= total/3, "power changed by %v out of %v", change, total)
+	return diff
+}
+
+func TestUpdateValidatorSetLimit(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	params := defaultParams()
+	params.MaxVals = 3
+	saveParams(store, params)
+	actors := newActors(4)
+	for _, bond := range bondsFromActors(actors[:3], []int{100, 100, 100}) {
+		saveValidatorBond(store, bond)
+	}
+	setTokens := func(actor sdk.Actor, tokens uint64) {
+		bond := loadValidatorBond(store, actor)
+		bond.BondedTokens, bond.BondedCoins = tokens, tokens
+		saveValidatorBond(store, bond)
+	}
+	setPower := func(actor sdk.Actor) uint64 {
+		return powerOf(loadValidatorSet(store), actor.Address)
+	}
+
+	// the first set has no limit
+	height := uint64(1)
+	require.Equal(3, len(UpdateValidatorSet(store, height)))
+
+	// a large bond is carried forward over the next blocks
+	setTokens(actors[0], 1000)
+	expected := []uint64{200, 333, 510, 746, 1000}
+	for _, power := range expected {
+		height++
+		updateSetBlock(t, store, height)
+		assert.Equal(power, setPower(actors[0]))
+	}
+	height++
+	assert.Empty(UpdateValidatorSet(store, height))
+
+	// so is a large unbond, the validator stays in the set after its bond is
+	// removed, until its power is down to zero
+	setTokens(actors[0], 0)
+	expected = []uint64{600, 334, 156, 38, 0}
+	for _, power := range expected {
+		height++
+		updateSetBlock(t, store, height)
+		LoadBonds(store).CleanupEmpty(store)
+		assert.Nil(loadValidatorBond(store, actors[0]))
+		assert.Equal(power, setPower(actors[0]))
+	}
+	assert.Equal(2, len(loadValidatorSet(store)))
+
+	// a new validator only joins once the validator it replaces has left
+	params.MaxVals = 2
+	saveParams(store, params)
+	bond := bondsFromActors(actors[3:], []int{500})[0]
+	bond.VotingPower = 0
+	saveValidatorBond(store, bond)
+	for setPower(actors[3]) < 500 {
+		require.True(height < 30, "the new validator never reached its power")
+		height++
+		updateSetBlock(t, store, height)
+		assert.True(len(loadValidatorSet(store)) <= params.MaxVals)
+		if setPower(actors[3]) > 0 {
+			assert.Equal(uint64(0), setPower(actors[2]))
+		}
+	}
+
+	// a jailed validator leaves right away, the others wait for the next block
+	jailed := loadValidatorBond(store, actors[3])
+	jailed.Jailed = true
+	saveValidatorBond(store, jailed)
+	setTokens(actors[1], 200)
+	height++
+	diff := UpdateValidatorSet(store, height)
+	require.Equal(1, len(diff))
+	assert.Equal(uint64(0), diff[0].Power)
+	assert.Equal(uint64(0), setPower(actors[3]))
+	assert.Equal(uint64(100), setPower(actors[1]))
+	height++
+	updateSetBlock(t, store, height)
+	assert.Equal(uint64(133), setPower(actors[1]))
+}
+
+func powerOf(validators []*abci.Validator, pubKey []byte) uint64 {
+	for _, val := range validators {
+		if bytes.Equal(val.PubKey, pubKey) {
+			return val.Power
+		}
+	}
+	return 0
+}
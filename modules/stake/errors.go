@@ -8,11 +8,29 @@ import (
 )
 
 var (
-	errBadBondingDenom    = fmt.Errorf("Invalid coin denomination")
-	errBadBondingAmount   = fmt.Errorf("Amount must be > 0")
-	errNoBondingAcct      = fmt.Errorf("No bond account for this (address, validator) pair")
-	errCommissionNegative = fmt.Errorf("Commission must be positive")
-	errCommissionHuge     = fmt.Errorf("Commission cannot be more than 100%")
+	errBadBondingDenom       = fmt.Errorf("Invalid coin denomination")
+	errBadBondingAmount      = fmt.Errorf("Amount must be > 0")
+	errNoBondingAcct         = fmt.Errorf("No bond account for this (address, validator) pair")
+	errValidatorEmpty        = fmt.Errorf("Cannot bond to an empty validator")
+	errNoExchangeRate        = fmt.Errorf("Validator has no coins backing its bond tokens")
+	errValidatorRevoked      = fmt.Errorf("Validator has been revoked")
+	errBadSlashFraction      = fmt.Errorf("Slash fraction must be between 0 and 1")
+	errValidatorNotJailed    = fmt.Errorf("Validator is not jailed")
+	errCandidateExistsAddr   = fmt.Errorf("Candidate already declared for this address")
+	errNoCandidateForAddress = fmt.Errorf("No candidate declared for this address")
+	errCandidateEmptyMoniker = fmt.Errorf("Candidate must have a moniker")
+	errCommissionNegative    = fmt.Errorf("Commission must be positive")
+	errCommissionHuge        = fmt.Errorf("Commission cannot be more than 100%")
+	errTotalSupplyTracked    = fmt.Errorf("total_supply is tracked by the provisions once they have started")
+
+	errBadPossessionSig     = fmt.Errorf("Signature does not prove the possession of the validator pubkey")
+	errMissingPossessionSig = fmt.Errorf("Candidate must prove the possession of the validator pubkey")
+
+	errGenesisNoAddress = fmt.Errorf("Genesis validator must have an address")
+	errGenesisNoPubKey  = fmt.Errorf("Genesis validator must have a pubkey")
+
+	errRedelegateSameValidator = fmt.Errorf("Cannot redelegate to the same validator")
+	errRedelegationMaturing    = fmt.Errorf("Cannot redelegate coins which are still being redelegated to this validator")
 
 	resBadValidatorAddr      = abci.ErrBaseUnknownAddress.AppendLog("Validator does not exist for that address")
 	resMissingSignature      = abci.ErrBaseInvalidSignature.AppendLog("Missing signature")
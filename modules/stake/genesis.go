@@ -0,0 +1,382 @@
+package stake
+
+import (
+	"encoding/json"
+	"fmt"
+
+	abci "github.com/tendermint/abci/types"
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/go-wire/data"
+
+	"github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+// GenesisValidator - a validator bonded from the genesis, set with the
+// "validator" genesis option. The sender is the account with the address, as
+// for the genesis accounts of the coin module, and the amount is bonded to the
+// validator as its own delegation. The coins are not taken from the sender,
+// they are placed in the hold account of the validator.
+type GenesisValidator struct {
+	Address     data.Bytes    `json:"address"`
+	PubKey      crypto.PubKey `json:"pub_key"`
+	Amount      coin.Coin     `json:"amount"`
+	Description Description   `json:"description"`
+}
+
+// GenesisCoins - coins placed in a hold account by the genesis, they are
+// credited within the store of the coin module on the first block
+type GenesisCoins struct {
+	HoldAccount sdk.Actor  `json:"hold_account"`
+	Coins       coin.Coins `json:"coins"`
+}
+
+// GenesisSetValidator - a validator of the set tendermint runs with when the
+// chain is exported, which may lag the voting power of the bonds until the
+// end of the epoch
+type GenesisSetValidator struct {
+	PubKey data.Bytes `json:"pub_key"`
+	Power  uint64     `json:"power"`
+}
+
+// Genesis - the whole stake section of the genesis, set with the "genesis"
+// option. The params replace any set by earlier options, those left out keep
+// their default value. New chains declare their validators, the state of an
+// exported chain is restored from the rest of the fields.
+type Genesis struct {
+	Params     Params             `json:"params"`
+	Validators []GenesisValidator `json:"validators"`
+
+	Bonds             []*ValidatorBond         `json:"bonds"`
+	ValidatorSet      []GenesisSetValidator    `json:"validator_set"`
+	Delegations       []*DelegatorBond         `json:"delegations"`
+	UnbondingQueue    []*UnbondingQueueElem    `json:"unbonding_queue"`
+	RedelegationQueue []*RedelegationQueueElem `json:"redelegation_queue"`
+	Provisions        *Provisions              `json:"provisions"`
+	SigningInfos      []*SigningInfo           `json:"signing_infos"`
+	Signers           []data.Bytes             `json:"signers"`
+	HoldCoins         []GenesisCoins           `json:"hold_coins"` // the coins of the hold accounts
+}
+
+// ParseGenesis - parse the JSON of the stake genesis and validate it as a
+// unit, unknown fields are errors
+func ParseGenesis(bz []byte) (genesis Genesis, err error) {
+	genesis.Params = defaultParams()
+	err = unmarshalFields(bz, &genesis, genesis)
+	if err != nil {
+		return genesis, fmt.Errorf("stake genesis: %v", err)
+	}
+
+	// the params and validators are written by hand, check them for typos
+	var fields struct {
+		Params     json.RawMessage   `json:"params"`
+		Validators []json.RawMessage `json:"validators"`
+	}
+	err = json.Unmarshal(bz, &fields)
+	if err != nil {
+		return genesis, fmt.Errorf("stake genesis: %v", err)
+	}
+	if len(fields.Params) > 0 {
+		err = unmarshalFields(fields.Params, new(Params), defaultParams())
+		if err != nil {
+			return genesis, fmt.Errorf("stake genesis params: %v", err)
+		}
+	}
+	for i, bz := range fields.Validators {
+		err = unmarshalFields(bz, new(GenesisValidator), GenesisValidator{})
+		if err != nil {
+			return genesis, fmt.Errorf("stake genesis validator %d: %v", i, err)
+		}
+	}
+
+	// everything is checked on an empty store before any of it is set
+	err = genesis.apply(state.NewMemKVStore())
+	return genesis, err
+}
+
+// unmarshal the JSON object into o, failing on the fields which aren't in the
+// JSON of known
+func unmarshalFields(bz []byte, o interface{}, known interface{}) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(bz, &fields)
+	if err != nil {
+		return err
+	}
+	knownBz, err := json.Marshal(known)
+	if err != nil {
+		return err
+	}
+	var knownFields map[string]json.RawMessage
+	err = json.Unmarshal(knownBz, &knownFields)
+	if err != nil {
+		return err
+	}
+	for field := range fields {
+		if _, ok := knownFields[field]; !ok {
+			return fmt.Errorf("unknown field %q", field)
+		}
+	}
+	return json.Unmarshal(bz, o)
+}
+
+// ExportGenesis - the stake genesis restoring the state of the store, along
+// with the coins of the hold accounts, which are held in the coin store. The
+// state is committed at height, the stored heights are moved back by it so
+// the first block of the new chain follows on from it.
+func ExportGenesis(store state.SimpleDB, holdCoins []GenesisCoins, height uint64) Genesis {
+	genesis := Genesis{
+		Params:            loadParams(store),
+		Bonds:             LoadBonds(store),
+		UnbondingQueue:    loadFullUnbondingQueue(store),
+		RedelegationQueue: loadFullRedelegationQueue(store),
+		HoldCoins:         holdCoins,
+	}
+
+	for _, model := range store.List(DelegatorBondKeyPrefix, prefixEnd(DelegatorBondKeyPrefix), 0) {
+		bond := new(DelegatorBond)
+		err := wire.ReadBinaryBytes(model.Value, bond)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		genesis.Delegations = append(genesis.Delegations, bond)
+	}
+	for _, model := range store.List(SigningInfoKeyPrefix, prefixEnd(SigningInfoKeyPrefix), 0) {
+		info := new(SigningInfo)
+		err := wire.ReadBinaryBytes(model.Value, info)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		genesis.SigningInfos = append(genesis.SigningInfos, info)
+	}
+	for _, signer := range loadSigners(store) {
+		genesis.Signers = append(genesis.Signers, signer)
+	}
+	for _, val := range loadValidatorSet(store) {
+		genesis.ValidatorSet = append(genesis.ValidatorSet, GenesisSetValidator{val.PubKey, val.Power})
+	}
+	if store.Get(ProvisionsKey) != nil {
+		provisions := loadProvisions(store)
+		genesis.Provisions = &provisions
+		// the supply is the one tracked by the provisions
+		genesis.Params.TotalSupply = provisions.TotalSupply
+	}
+
+	for _, bond := range genesis.Bonds {
+		bond.JailedUntil = ShiftHeight(bond.JailedUntil, height)
+		bond.CommissionChangeHeight = ShiftHeight(bond.CommissionChangeHeight, height)
+	}
+	for _, elem := range genesis.UnbondingQueue {
+		elem.HeightRelease = ShiftHeight(elem.HeightRelease, height)
+	}
+	for _, elem := range genesis.RedelegationQueue {
+		elem.HeightRelease = ShiftHeight(elem.HeightRelease, height)
+	}
+	for _, info := range genesis.SigningInfos {
+		info.StartHeight = ShiftHeight(info.StartHeight, height)
+	}
+	return genesis
+}
+
+// ShiftHeight - the height of a chain exported at the export height, on the
+// new chain. Heights up to the export height are in the past of the new
+// chain, they become 0 as for a height which was never set.
+func ShiftHeight(height, exportHeight uint64) uint64 {
+	if height <= exportHeight {
+		return 0
+	}
+	return height - exportHeight
+}
+
+// set the params, restore the exported state and add the validators
+func (genesis Genesis) apply(store state.SimpleDB) error {
+	err := genesis.Params.Validate()
+	if err != nil {
+		return fmt.Errorf("stake genesis params: %v", err)
+	}
+	saveParams(store, genesis.Params)
+
+	err = genesis.restore(store)
+	if err != nil {
+		return fmt.Errorf("stake genesis: %v", err)
+	}
+	// the coins of the genesis are only held by the derived hold accounts
+	store.Set(HoldAccountsMigratedKey, []byte{0x01})
+
+	for i, val := range genesis.Validators {
+		err = addGenesisValidator(store, val)
+		if err != nil {
+			return fmt.Errorf("stake genesis validator %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// restore the state of an exported chain
+func (genesis Genesis) restore(store state.SimpleDB) error {
+	for _, bond := range genesis.Bonds {
+		if loadValidatorBond(store, bond.Sender) != nil {
+			return errCandidateExistsAddr
+		}
+		if loadValidatorBondByPubKey(store, bond.PubKey) != nil {
+			return fmt.Errorf("bond pubkey %X is used by another validator", bond.PubKey)
+		}
+		saveValidatorBond(store, bond)
+	}
+	if len(genesis.Bonds) > 0 {
+		validators := LoadBonds(store).GetValidators(store)
+		saveValidatorSet(store, validators)
+		saveNextValidatorSet(store, validators)
+	}
+
+	// tendermint starts with the exported set, the next set is applied to it
+	// at the end of the epoch
+	if len(genesis.ValidatorSet) > 0 {
+		var validators []*abci.Validator
+		for _, val := range genesis.ValidatorSet {
+			if loadValidatorBondByPubKey(store, val.PubKey) == nil {
+				return fmt.Errorf("the validator set has the unknown validator %X", []byte(val.PubKey))
+			}
+			validators = append(validators, &abci.Validator{PubKey: val.PubKey, Power: val.Power})
+		}
+		saveValidatorSet(store, validators)
+	}
+
+	for _, bond := range genesis.Delegations {
+		if loadValidatorBondByPubKey(store, bond.PubKey) == nil {
+			return fmt.Errorf("delegation to the unknown validator %X", bond.PubKey)
+		}
+		saveDelegatorBond(store, bond)
+	}
+	for _, elem := range genesis.UnbondingQueue {
+		saveUnbondingQueueElem(store, elem)
+	}
+	for _, elem := range genesis.RedelegationQueue {
+		saveRedelegationQueueElem(store, elem)
+	}
+	if genesis.Provisions != nil {
+		if genesis.Params.TotalSupply != genesis.Provisions.TotalSupply {
+			return fmt.Errorf("total_supply %d is not the supply of the provisions %d",
+				genesis.Params.TotalSupply, genesis.Provisions.TotalSupply)
+		}
+		saveProvisions(store, *genesis.Provisions)
+	}
+	for _, info := range genesis.SigningInfos {
+		saveSigningInfo(store, info)
+	}
+	if len(genesis.Signers) > 0 {
+		signers := make([][]byte, len(genesis.Signers))
+		for i, signer := range genesis.Signers {
+			signers[i] = signer
+		}
+		saveSigners(store, signers)
+	}
+
+	for _, genesisCoins := range genesis.HoldCoins {
+		if !genesisCoins.Coins.IsValid() {
+			return fmt.Errorf("invalid coins %v", genesisCoins.Coins)
+		}
+	}
+	if len(genesis.HoldCoins) > 0 {
+		saveGenesisCoins(store, append(loadGenesisCoins(store), genesis.HoldCoins...))
+	}
+	return nil
+}
+
+// set the whole stake genesis from its JSON
+func initGenesis(store state.SimpleDB, value string) error {
+	genesis, err := ParseGenesis([]byte(value))
+	if err != nil {
+		return err
+	}
+	return genesis.apply(store)
+}
+
+// add a validator bond from the JSON of a GenesisValidator
+func initValidator(store state.SimpleDB, value string) error {
+	var val GenesisValidator
+	err := json.Unmarshal([]byte(value), &val)
+	if err != nil {
+		return fmt.Errorf("cannot parse the genesis validator: %v", err)
+	}
+	return addGenesisValidator(store, val)
+}
+
+// add a validator bond, the voting power is set straight away so the
+// validator set matches the one of the genesis
+func addGenesisValidator(store state.SimpleDB, val GenesisValidator) error {
+	if len(val.Address) == 0 {
+		return errGenesisNoAddress
+	}
+	if val.PubKey.Empty() {
+		return errGenesisNoPubKey
+	}
+	if val.Amount.Denom != loadParams(store).AllowedBondDenom {
+		return errBadBondingDenom
+	}
+	if val.Amount.Amount <= 0 {
+		return errBadBondingAmount
+	}
+
+	sender := auth.SigPerm(val.Address)
+	tx := TxDeclareCandidacy{
+		Amount:      val.Amount,
+		PubKey:      wire.BinaryBytes(val.PubKey),
+		Description: val.Description,
+	}
+	err := checkCandidacy(tx, sender, store)
+	if err != nil {
+		return err
+	}
+
+	res := runTxDeclareCandidacy(store, sender, getHoldAccount(sender),
+		genesisTransferFn(store), tx)
+	if res.IsErr() {
+		return res
+	}
+
+	bonds := LoadBonds(store)
+	bonds.UpdateVotingPower(store)
+	validators := bonds.GetValidators(store)
+	saveValidatorSet(store, validators)
+	saveNextValidatorSet(store, validators)
+	return nil
+}
+
+// record the coins moved into a hold account, instead of taking them from the
+// sender, to be credited by ProcessGenesisCoins
+func genesisTransferFn(store state.SimpleDB) transferFn {
+	return func(_, receiver sdk.Actor, coins coin.Coins) abci.Result {
+		genesisCoins := loadGenesisCoins(store)
+		genesisCoins = append(genesisCoins, GenesisCoins{receiver, coins})
+		saveGenesisCoins(store, genesisCoins)
+		return abci.OK
+	}
+}
+
+// ProcessGenesisCoins - credit the coins bonded by the genesis validators to
+// their hold accounts, within coinStore, the store of the coin module. The
+// genesis options cannot reach the coin store, so it is done on the first
+// block, before any transaction can unbond them. Does nothing afterwards.
+func ProcessGenesisCoins(store, coinStore state.SimpleDB) error {
+	return processGenesisCoins(store, storeChangeCoinsFn(coinStore))
+}
+
+// separated for testing
+func processGenesisCoins(store state.SimpleDB, mintFn changeCoinsFn) error {
+	genesisCoins := loadGenesisCoins(store)
+	if len(genesisCoins) == 0 {
+		return nil
+	}
+
+	for _, genesisCoins := range genesisCoins {
+		res := mintFn(genesisCoins.HoldAccount, genesisCoins.Coins)
+		if res.IsErr() {
+			return res
+		}
+	}
+	store.Remove(GenesisCoinsKey)
+	return nil
+}
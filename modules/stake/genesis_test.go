@@ -0,0 +1,298 @@
+package stake
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+
+	"github.com/cosmos/cosmos-sdk/modules/auth"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+func genesisValidator(address string, pubKey crypto.PubKey, amount string) string {
+	return fmt.Sprintf(`{"address": "%X", "pub_key": {"type": "ed25519", "data": "%X"},`+
+		` "amount": %v, "description": {"moniker": "%v"}}`,
+		address, pubKey.Bytes(), amount, address)
+}
+
+func TestGenesisValidators(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	pk1 := crypto.GenPrivKeyEd25519().PubKey()
+	pk2 := crypto.GenPrivKeyEd25519().PubKey()
+	h := Handler{}
+
+	require.Nil(h.initState(stakingModuleName, "validator",
+		genesisValidator("val1", pk1, `{"denom": "fermion", "amount": 100}`), store))
+	require.Nil(h.initState(stakingModuleName, "validator",
+		genesisValidator("val2", pk2, `{"denom": "fermion", "amount": 300}`), store))
+
+	// the bonds are known to the app, with their voting power, from the genesis
+	sender := auth.SigPerm([]byte("val1"))
+	bond := loadValidatorBond(store, sender)
+	require.NotNil(bond)
+	assert.Equal(wire.BinaryBytes(pk1), bond.PubKey)
+	assert.Equal(uint64(100), bond.BondedCoins)
+	assert.Equal(uint64(100), bond.VotingPower)
+	assert.Equal("val1", bond.Description.Moniker)
+	assert.Equal(uint64(100), BondedCoins(store, sender))
+
+	validators := loadValidatorSet(store)
+	require.Equal(2, len(validators))
+	assert.Equal(wire.BinaryBytes(pk2), validators[0].PubKey)
+	assert.Equal(uint64(300), validators[0].Power)
+
+	// so the first block has no validator set changes
+	bonds := LoadBonds(store)
+	startVal := bonds.GetValidators(store)
+	assert.False(bonds.UpdateVotingPower(store))
+	assert.Empty(ValidatorsDiff(startVal, bonds.GetValidators(store), store))
+
+	// the coins are credited to the hold accounts once
+	accStore := map[string]int64{}
+	require.Nil(processGenesisCoins(store, dummyChangeCoinsFn(accStore)))
+	assert.Equal(int64(100), accStore[string(bond.HoldAccount.Address)])
+	assert.Equal(int64(0), accStore[string(sender.Address)])
+	require.Nil(processGenesisCoins(store, dummyChangeCoinsFn(accStore)))
+	assert.Equal(int64(100), accStore[string(bond.HoldAccount.Address)])
+	assert.Equal(2, len(accStore))
+}
+
+func TestGenesisValidatorsInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	store := state.NewMemKVStore()
+	pk := crypto.GenPrivKeyEd25519().PubKey()
+	fermions := `{"denom": "fermion", "amount": 100}`
+	h := Handler{}
+
+	assert.Nil(h.initState(stakingModuleName, "validator",
+		genesisValidator("val1", pk, fermions), store))
+
+	testCases := []struct {
+		value string
+		err   error
+	}{
+		{genesisValidator("val1", crypto.GenPrivKeyEd25519().PubKey(), fermions), errCandidateExistsAddr},
+		{genesisValidator("val2", crypto.GenPrivKeyEd25519().PubKey(), `{"denom": "atom", "amount": 100}`), errBadBondingDenom},
+		{genesisValidator("val2", crypto.GenPrivKeyEd25519().PubKey(), `{"denom": "fermion", "amount": 0}`), errBadBondingAmount},
+		{`{"pub_key": null, "address": "` + hex.EncodeToString([]byte("val2")) + `"}`, errGenesisNoPubKey},
+		{`{}`, errGenesisNoAddress},
+	}
+	for _, tc := range testCases {
+		assert.Equal(tc.err, h.initState(stakingModuleName, "validator", tc.value, store), tc.value)
+	}
+
+	// a pubkey can only be used by one validator
+	assert.NotNil(h.initState(stakingModuleName, "validator",
+		genesisValidator("val2", pk, fermions), store))
+	assert.NotNil(h.initState(stakingModuleName, "validator", "not json", store))
+	assert.Equal(1, len(LoadBonds(store)))
+	assert.Equal(1, len(loadGenesisCoins(store)))
+}
+
+func TestParseGenesis(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	pk1 := crypto.GenPrivKeyEd25519().PubKey()
+	pk2 := crypto.GenPrivKeyEd25519().PubKey()
+	atoms := `{"denom": "atom", "amount": 10}`
+	genesisJSON := func(params string, validators ...string) string {
+		return fmt.Sprintf(`{"params": %v, "validators": [%v]}`,
+			params, strings.Join(validators, ","))
+	}
+
+	// the params left out keep their default value
+	genesis, err := ParseGenesis([]byte(genesisJSON(
+		`{"max_vals": 5, "allowed_bond_denom": "atom", "goal_bonded": {"num": 1, "denom": 2}}`,
+		genesisValidator("val1", pk1, atoms), genesisValidator("val2", pk2, atoms))))
+	require.Nil(err)
+	params := defaultParams()
+	params.MaxVals = 5
+	params.AllowedBondDenom = "atom"
+	params.GoalBonded = NewFraction(1, 2)
+	assert.Equal(params, genesis.Params)
+	require.Equal(2, len(genesis.Validators))
+	assert.Equal(pk2, genesis.Validators[1].PubKey)
+
+	_, err = ParseGenesis([]byte(`{}`))
+	assert.Nil(err)
+
+	testCases := []struct {
+		value, errContains string
+	}{
+		{`{"parmas": {}}`, `unknown field "parmas"`},
+		{genesisJSON(`{"gas_unbound": 1}`), `unknown field "gas_unbound"`},
+		{genesisJSON(`{"max_vals": "many"}`), "max_vals"},
+		{genesisJSON(`{"max_vals": 0}`), "max_vals must be positive"},
+		{genesisJSON(`{"goal_bonded": {"num": 3, "denom": 2}}`), "goal_bonded"},
+		{genesisJSON(`{}`, `{"address": "AA", "pubkey": {}}`), `validator 0: unknown field "pubkey"`},
+		{genesisJSON(`{}`, genesisValidator("val1", pk1, atoms)), "validator 0: " + errBadBondingDenom.Error()},
+		{genesisJSON(`{"allowed_bond_denom": "atom"}`, genesisValidator("val1", pk1, atoms),
+			genesisValidator("val2", pk1, atoms)), "validator 1: cannot declare a candidate with pubkey"},
+		{`[]`, "stake genesis"},
+	}
+	for _, tc := range testCases {
+		_, err := ParseGenesis([]byte(tc.value))
+		if assert.NotNil(err, tc.value) {
+			assert.Contains(err.Error(), tc.errContains, tc.value)
+		}
+	}
+}
+
+func TestInitGenesis(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	pk1 := crypto.GenPrivKeyEd25519().PubKey()
+	pk2 := crypto.GenPrivKeyEd25519().PubKey()
+	h := Handler{}
+
+	// nothing is set when any part of the genesis is invalid
+	value := fmt.Sprintf(`{"params": {"max_vals": 5}, "validators": [%v, %v]}`,
+		genesisValidator("val1", pk1, `{"denom": "fermion", "amount": 10}`),
+		genesisValidator("val2", pk2, `{"denom": "atom", "amount": 10}`))
+	assert.NotNil(h.initState(stakingModuleName, "genesis", value, store))
+	assert.Nil(store.Get(ParamKey))
+	assert.Empty(LoadBonds(store))
+
+	value = fmt.Sprintf(`{"params": {"max_vals": 5}, "validators": [%v, %v]}`,
+		genesisValidator("val1", pk1, `{"denom": "fermion", "amount": 10}`),
+		genesisValidator("val2", pk2, `{"denom": "fermion", "amount": 20}`))
+	require.Nil(h.initState(stakingModuleName, "genesis", value, store))
+	assert.Equal(5, loadParams(store).MaxVals)
+	assert.Equal(2, len(loadValidatorSet(store)))
+	assert.Equal(2, len(loadGenesisCoins(store)))
+}
+
+func TestExportGenesis(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	params := defaultParams()
+	params.TotalSupply = 10000
+	params.SignedBlocksWindow = 10
+	saveParams(store, params)
+	senders, accStore := initAccounts(3, 1000)
+	delegator := senders[2]
+
+	// two validators, a delegation, an unbonding and a redelegation in flight
+	txFrom := newTxDeclareCandidacy(100, "pubkey1")
+	got := runTxDeclareCandidacy(store, senders[0], getHoldAccount(senders[0]),
+		dummyTransferFn(accStore), txFrom)
+	require.True(got.IsOK(), "expected declare candidacy tx to be ok, got %v", got)
+	txTo := newTxDeclareCandidacy(200, "pubkey2")
+	got = runTxDeclareCandidacy(store, senders[1], getHoldAccount(senders[1]),
+		dummyTransferFn(accStore), txTo)
+	require.True(got.IsOK(), "expected declare candidacy tx to be ok, got %v", got)
+	UpdateValidatorSet(store, 1)
+
+	got = runTxDelegate(store, delegator, dummyTransferFn(accStore),
+		TxDelegate{Amount: coin.Coin{"fermion", 100}, PubKey: txFrom.PubKey})
+	require.True(got.IsOK(), "expected delegate tx to be ok, got %v", got)
+	got = runTxUnbondDelegation(store, delegator, 5,
+		TxUnbondDelegation{Amount: coin.Coin{"fermion", 20}, PubKey: txFrom.PubKey})
+	require.True(got.IsOK(), "expected unbond delegation tx to be ok, got %v", got)
+	got = runTxRedelegate(store, delegator, 5, dummyTransferFn(accStore),
+		TxRedelegate{coin.Coin{"fermion", 30}, txFrom.PubKey, txTo.PubKey})
+	require.True(got.IsOK(), "expected redelegate tx to be ok, got %v", got)
+
+	saveProvisions(store, initialProvisions(params))
+	require.Nil(processProvisions(store, dummyChangeCoinsFn(accStore)))
+	UpdateValidatorSet(store, 5)
+	for height := uint64(6); height < 9; height++ {
+		require.Nil(processAbsentValidators(store, dummyChangeCoinsFn(accStore), height, []int32{1}))
+	}
+
+	var holdCoins []GenesisCoins
+	for _, sender := range senders[:2] {
+		holder := getHoldAccount(sender)
+		amount := accStore[string(holder.Address)]
+		holdCoins = append(holdCoins, GenesisCoins{holder, coin.Coins{{"fermion", amount}}})
+	}
+
+	// the exported genesis restores the same state in a new store
+	bz, err := json.Marshal(ExportGenesis(store, holdCoins, 0))
+	require.Nil(err)
+	genesis, err := ParseGenesis(bz)
+	require.Nil(err, "%s", bz)
+	newStore := state.NewMemKVStore()
+	require.Nil(genesis.apply(newStore))
+
+	assert.Equal(holdCoins, loadGenesisCoins(newStore))
+	newStore.Remove(GenesisCoinsKey)
+
+	// the params carry the supply tracked by the provisions
+	assert.Equal(loadProvisions(store).TotalSupply, genesis.Params.TotalSupply)
+	params = loadParams(store)
+	params.TotalSupply = genesis.Params.TotalSupply
+	saveParams(store, params)
+	require.Nil(migrateHoldAccounts(store, dummyTransferFn(accStore)))
+	models := store.List(nil, []byte{0xff}, 0)
+	assert.NotEmpty(models)
+	assert.Equal(models, newStore.List(nil, []byte{0xff}, 0))
+	assert.Equal(2, len(genesis.Bonds))
+	assert.Equal(4, len(genesis.Delegations)) // with the self bonds
+	assert.Equal(1, len(genesis.UnbondingQueue))
+	assert.Equal(1, len(genesis.RedelegationQueue))
+	assert.Equal(2, len(genesis.SigningInfos))
+
+	// the state can only be restored once
+	assert.NotNil(genesis.apply(newStore))
+
+	// a total_supply other than the one of the provisions would be ignored
+	genesis.Params.TotalSupply++
+	assert.NotNil(genesis.apply(state.NewMemKVStore()))
+	genesis.Params.TotalSupply--
+
+	// exported at a later height, the heights are moved back by it
+	bond := loadValidatorBond(store, senders[0])
+	bond.Jailed, bond.JailedUntil = true, 20
+	saveValidatorBond(store, bond)
+	elem := genesis.UnbondingQueue[0]
+	shifted := ExportGenesis(store, holdCoins, 8)
+	assert.Equal(elem.HeightRelease-8, shifted.UnbondingQueue[0].HeightRelease)
+	assert.Equal(genesis.RedelegationQueue[0].HeightRelease-8, shifted.RedelegationQueue[0].HeightRelease)
+	for _, bond := range shifted.Bonds {
+		if bond.Jailed {
+			assert.Equal(uint64(12), bond.JailedUntil)
+		}
+	}
+	for _, info := range shifted.SigningInfos {
+		assert.Equal(uint64(0), info.StartHeight) // started before the export
+	}
+
+	// within an epoch the validator set lags the voting power of the bonds,
+	// the exported set is restored rather than the one of the bonds
+	lagging := []*abci.Validator{{PubKey: txTo.PubKey, Power: 150}}
+	saveValidatorSet(store, lagging)
+	genesis = ExportGenesis(store, holdCoins, 0)
+	require.Equal([]GenesisSetValidator{{txTo.PubKey, 150}}, genesis.ValidatorSet)
+	newStore = state.NewMemKVStore()
+	require.Nil(genesis.apply(newStore))
+	assert.Equal(lagging, loadValidatorSet(newStore))
+	assert.Equal(LoadBonds(newStore).GetValidators(newStore), loadNextValidatorSet(newStore))
+
+	genesis.ValidatorSet[0].PubKey = []byte("unknown")
+	assert.NotNil(genesis.apply(state.NewMemKVStore()))
+}
+
+func TestShiftHeight(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint64(0), ShiftHeight(0, 10))
+	assert.Equal(uint64(0), ShiftHeight(10, 10))
+	assert.Equal(uint64(1), ShiftHeight(11, 10))
+	assert.Equal(uint64(11), ShiftHeight(11, 0))
+}
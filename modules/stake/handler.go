@@ -1,10 +1,14 @@
 package stake
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"strconv"
 
 	abci "github.com/tendermint/abci/types"
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
 
 	"github.com/tendermint/tmlibs/log"
 
@@ -57,32 +61,148 @@ func (Handler) initState(module, key, value string, store state.SimpleDB) error
 	if module != stakingModuleName {
 		return errors.ErrUnknownModule(module)
 	}
+	switch key {
+	case "genesis":
+		return initGenesis(store, value)
+	case "validator":
+		return initValidator(store, value)
+	}
+	return SetParam(store, key, value)
+}
+
+// SetParam - set the param with the genesis key to the value, used for the
+// genesis options
+func SetParam(store state.SimpleDB, key, value string) error {
+	return SetParams(store, []string{key}, []string{value})
+}
+
+// SetParams - set the params with the genesis keys to the values, used for
+// the param changes passed by governance. The changes are applied together,
+// none are set if the params they result in are invalid. Once the provisions
+// have started they track the supply, total_supply can no longer be changed.
+func SetParams(store state.SimpleDB, keys, values []string) error {
+	if store.Get(ProvisionsKey) != nil {
+		for _, key := range keys {
+			if key == "total_supply" {
+				return errTotalSupplyTracked
+			}
+		}
+	}
 
 	params := loadParams(store)
+	err := params.setAll(keys, values)
+	if err != nil {
+		return err
+	}
+	saveParams(store, params)
+	return nil
+}
+
+// ValidateParam - check the value is valid for the param with the genesis
+// key on its own, the checks between params depend on the other params
+func ValidateParam(key, value string) error {
+	params := defaultParams()
+	err := params.set(key, value)
+	if err != nil {
+		return err
+	}
+	return params.validateFields()
+}
+
+// ValidateParams - check the values for the params with the genesis keys,
+// applied together on the params, result in valid params
+func ValidateParams(params Params, keys, values []string) error {
+	return params.setAll(keys, values)
+}
+
+// set each param with the genesis key to its value, and check the params
+// resulting from all of the changes
+func (params *Params) setAll(keys, values []string) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("got %d param keys but %d values", len(keys), len(values))
+	}
+	for i, key := range keys {
+		err := params.set(key, values[i])
+		if err != nil {
+			return err
+		}
+	}
+	return params.Validate()
+}
+
+// set the param with the genesis key from its string value
+func (params *Params) set(key, value string) error {
 	switch key {
 	case "allowed_bond_denom":
 		params.AllowedBondDenom = value
+	case "inflation_rate_min",
+		"inflation_rate_max",
+		"goal_bonded",
+		"slash_fraction_double_sign",
+		"min_signed_per_window",
+		"slash_fraction_downtime",
+		"max_power_change_per_block":
+		f, err := ParseFraction(value)
+		if err != nil {
+			return err
+		}
+		if f.Num < 0 || f.Num > f.Denom {
+			return fmt.Errorf("%v must be between 0 and 1, got %v", key, f)
+		}
+
+		switch key {
+		case "inflation_rate_min":
+			params.InflationRateMin = f
+		case "inflation_rate_max":
+			params.InflationRateMax = f
+		case "goal_bonded":
+			params.GoalBonded = f
+		case "slash_fraction_double_sign":
+			params.SlashFractionDoubleSign = f
+		case "min_signed_per_window":
+			params.MinSignedPerWindow = f
+		case "slash_fraction_downtime":
+			params.SlashFractionDowntime = f
+		case "max_power_change_per_block":
+			params.MaxPowerChangePerBlock = f
+		}
 	case "max_vals",
+		"unbonding_period",
+		"total_supply",
+		"blocks_per_year",
+		"signed_blocks_window",
+		"downtime_jail_period",
+		"epoch_length",
 		"gas_bond",
 		"gas_unbond":
-		i, err := strconv.Atoi(value)
+		i, err := strconv.ParseUint(value, 10, 64)
 		if err != nil {
-			return fmt.Errorf("input must be integer, Error: %v", err.Error())
+			return fmt.Errorf("input must be a non-negative integer, Error: %v", err.Error())
 		}
 
 		switch key {
 		case "max_vals":
-			params.MaxVals = i
+			params.MaxVals = int(i)
+		case "unbonding_period":
+			params.UnbondingPeriod = i
+		case "total_supply":
+			params.TotalSupply = i
+		case "blocks_per_year":
+			params.BlocksPerYear = i
+		case "signed_blocks_window":
+			params.SignedBlocksWindow = i
+		case "downtime_jail_period":
+			params.DowntimeJailPeriod = i
+		case "epoch_length":
+			params.EpochLength = i
 		case "gas_bond":
-			params.GasBond = uint64(i)
-		case "gas_unbound":
-			params.GasUnbond = uint64(i)
+			params.GasBond = i
+		case "gas_unbond":
+			params.GasUnbond = i
 		}
 	default:
 		return errors.ErrUnknownKey(key)
 	}
-
-	saveParams(store, params)
 	return nil
 }
 
@@ -104,18 +224,85 @@ func (h Handler) CheckTx(ctx sdk.Context, store state.SimpleDB,
 	params := loadParams(store)
 	// return the fee for each tx type
 	switch txInner := tx.Unwrap().(type) {
+	case TxDeclareCandidacy:
+		return sdk.NewCheck(params.GasBond, ""),
+			checkTxDeclareCandidacy(txInner, sender, ctx.ChainID(), store)
+	case TxEditCandidacy:
+		return sdk.NewCheck(params.GasUnbond, ""),
+			checkTxEditCandidacy(txInner, sender, store)
 	case TxBond:
 		return sdk.NewCheck(params.GasBond, ""),
-			checkTxBond(txInner, sender, store)
+			checkTxBond(txInner, sender, ctx.ChainID(), store)
 	case TxUnbond:
 		return sdk.NewCheck(params.GasUnbond, ""),
 			checkTxUnbond(txInner, sender, store)
+	case TxDelegate:
+		return sdk.NewCheck(params.GasBond, ""),
+			checkTxDelegate(txInner, store)
+	case TxUnbondDelegation:
+		return sdk.NewCheck(params.GasUnbond, ""),
+			checkTxUnbondDelegation(txInner, sender, store)
+	case TxRedelegate:
+		return sdk.NewCheck(params.GasBond, ""),
+			checkTxRedelegate(txInner, sender, store)
+	case TxUnjail:
+		return sdk.NewCheck(params.GasUnbond, ""),
+			checkTxUnjail(txInner, sender, ctx.BlockHeight(), store)
+	case TxEditValidator:
+		return sdk.NewCheck(params.GasUnbond, ""),
+			checkTxEditValidator(txInner, sender, ctx.BlockHeight(), store)
 	}
 
 	return res, errors.ErrUnknownTxType("GTH")
 }
 
-func checkTxBond(tx TxBond, sender sdk.Actor, store state.SimpleDB) error {
+// a candidate must prove it controls the validator pubkey, so that nobody can
+// claim the pubkey of another validator first
+func checkTxDeclareCandidacy(tx TxDeclareCandidacy, sender sdk.Actor,
+	chainID string, store state.SimpleDB) error {
+
+	err := checkCandidacy(tx, sender, store)
+	if err != nil {
+		return err
+	}
+	if len(tx.Signature) == 0 {
+		return errMissingPossessionSig
+	}
+	return checkPossession(sender, chainID, tx.PubKey, tx.Signature)
+}
+
+// the checks of a new candidate, without the proof of possession which the
+// genesis validators don't need
+func checkCandidacy(tx TxDeclareCandidacy, sender sdk.Actor, store state.SimpleDB) error {
+	// check denom
+	if tx.Amount.Denom != loadParams(store).AllowedBondDenom {
+		return errBadBondingDenom
+	}
+
+	// each address can only declare one candidate, and each pubkey
+	// can only belong to one candidate
+	bond := loadValidatorBond(store, sender)
+	if bond != nil {
+		return errCandidateExistsAddr
+	}
+	bond = loadValidatorBondByPubKey(store, tx.PubKey)
+	if bond != nil {
+		return fmt.Errorf("cannot declare a candidate with pubkey used by another validator"+
+			" PubKey %v already registered with %v validator address",
+			bond.PubKey, bond.Sender)
+	}
+	return nil
+}
+
+func checkTxEditCandidacy(tx TxEditCandidacy, sender sdk.Actor, store state.SimpleDB) error {
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return errNoCandidateForAddress
+	}
+	return bond.Description.Update(tx.Description).Validate()
+}
+
+func checkTxBond(tx TxBond, sender sdk.Actor, chainID string, store state.SimpleDB) error {
 	// TODO check the sender has enough coins to bond
 	//acc := coin.Account{}
 	//// vvv this causes nil pointer ref error INSIDE of GetParsed
@@ -133,20 +320,23 @@ func checkTxBond(tx TxBond, sender sdk.Actor, store state.SimpleDB) error {
 		return fmt.Errorf("Invalid coin denomination")
 	}
 
-	// check to see if the pubkey has been registered before,
-	// if it has been used ensure that the validator account is same
-	// to prevent accidentally bonding to validator other than you
-	bonds := LoadBonds(store)
-	_, bond := bonds.GetByPubKey(tx.PubKey)
-	if bond != nil {
-		if !bond.Sender.Equals(sender) {
-			return fmt.Errorf("cannot bond tokens to pubkey used by another validator"+
-				" PubKey %v already registered with %v validator address",
-				bond.PubKey, bond.Sender)
-		}
+	// bonding only adds stake to the sender's existing candidate, the pubkey
+	// is checked to prevent accidentally bonding to another validator
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return errNoCandidateForAddress
+	}
+	if len(tx.PubKey) > 0 && !bytes.Equal(tx.PubKey, bond.PubKey) {
+		return fmt.Errorf("cannot bond tokens to pubkey %X,"+
+			" the candidate for this address has pubkey %X", tx.PubKey, bond.PubKey)
 	}
 
-	return nil
+	// a revoked validator can never be bonded to again
+	if bond.Revoked {
+		return errValidatorRevoked
+	}
+
+	return checkPossession(sender, chainID, bond.PubKey, tx.Signature)
 }
 
 func checkTxUnbond(tx TxUnbond, sender sdk.Actor, store state.SimpleDB) error {
@@ -155,12 +345,135 @@ func checkTxUnbond(tx TxUnbond, sender sdk.Actor, store state.SimpleDB) error {
 		return fmt.Errorf("Invalid coin denomination")
 	}
 
-	// check if have enough tokens to unbond
-	bonds := LoadBonds(store)
-	_, bond := bonds.Get(sender)
-	if bond.BondedTokens < uint64(tx.Amount.Amount) {
-		return fmt.Errorf("not enough bond tokens to unbond, have %v, trying to unbond %v",
-			bond.BondedTokens, tx.Amount)
+	// the validator may only unbond its own tokens, not those delegated to it
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return resNoValidatorForAddress
+	}
+	return checkUnbondAmount(sender, bond.PubKey, tx.Amount, store)
+}
+
+func checkTxDelegate(tx TxDelegate, store state.SimpleDB) error {
+	// check denom
+	if tx.Amount.Denom != loadParams(store).AllowedBondDenom {
+		return errBadBondingDenom
+	}
+
+	// delegation is only possible to an existing validator
+	bond := loadValidatorBondByPubKey(store, tx.PubKey)
+	if bond == nil {
+		return resBadValidatorAddr
+	}
+	if bond.Revoked {
+		return errValidatorRevoked
+	}
+	return nil
+}
+
+func checkTxUnbondDelegation(tx TxUnbondDelegation, sender sdk.Actor, store state.SimpleDB) error {
+	// check denom
+	if tx.Amount.Denom != loadParams(store).AllowedBondDenom {
+		return errBadBondingDenom
+	}
+
+	bond := loadValidatorBondByPubKey(store, tx.PubKey)
+	if bond == nil {
+		return resBadValidatorAddr
+	}
+	return checkUnbondAmount(sender, tx.PubKey, tx.Amount, store)
+}
+
+func checkTxRedelegate(tx TxRedelegate, sender sdk.Actor, store state.SimpleDB) error {
+	// check denom
+	if tx.Amount.Denom != loadParams(store).AllowedBondDenom {
+		return errBadBondingDenom
+	}
+
+	bond := loadValidatorBondByPubKey(store, tx.PubKeyFrom)
+	if bond == nil {
+		return resBadValidatorAddr
+	}
+	bond = loadValidatorBondByPubKey(store, tx.PubKeyTo)
+	if bond == nil {
+		return resBadValidatorAddr
+	}
+	if bond.Revoked {
+		return errValidatorRevoked
+	}
+
+	// coins redelegated to a validator can only be moved on once they are no
+	// longer slashable for the previous validator, so they cannot escape a slash
+	for _, elem := range loadFullRedelegationQueue(store) {
+		if elem.Delegator.Equals(sender) && bytes.Equal(elem.PubKeyTo, tx.PubKeyFrom) {
+			return errRedelegationMaturing
+		}
+	}
+	return checkUnbondAmount(sender, tx.PubKeyFrom, tx.Amount, store)
+}
+
+func checkTxUnjail(tx TxUnjail, sender sdk.Actor, height uint64, store state.SimpleDB) error {
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return resNoValidatorForAddress
+	}
+	if bond.Revoked {
+		return errValidatorRevoked
+	}
+	if !bond.Jailed {
+		return errValidatorNotJailed
+	}
+	if height < bond.JailedUntil {
+		return fmt.Errorf("Validator is jailed until height %v", bond.JailedUntil)
+	}
+	return nil
+}
+
+func checkTxEditValidator(tx TxEditValidator, sender sdk.Actor,
+	height uint64, store state.SimpleDB) error {
+
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return resNoValidatorForAddress
+	}
+	return bond.EditCommission(tx.Commission, height, loadParams(store))
+}
+
+// check the optional signature proving the sender controls the private key of
+// the validator pubkey, no signature is accepted
+func checkPossession(sender sdk.Actor, chainID string, pubKey, signature []byte) error {
+	if len(signature) == 0 {
+		return nil
+	}
+
+	pk, err := crypto.PubKeyFromBytes(pubKey)
+	if err != nil || pk.Empty() {
+		return errBadPossessionSig
+	}
+	sig, err := crypto.SignatureFromBytes(signature)
+	if err != nil || sig.Empty() {
+		return errBadPossessionSig
+	}
+	if !pk.VerifyBytes(PossessionSignBytes(chainID, sender), sig) {
+		return errBadPossessionSig
+	}
+	return nil
+}
+
+// check if the delegator has enough tokens bonded to the validator to unbond
+func checkUnbondAmount(delegator sdk.Actor, pubKey []byte,
+	amount coin.Coin, store state.SimpleDB) error {
+
+	delegatorBond := loadDelegatorBond(store, delegator, pubKey)
+	if delegatorBond == nil {
+		return resNoDelegatorForAddress
+	}
+
+	// the bond tokens are worth coins at the validator's exchange rate
+	bond := loadValidatorBondByPubKey(store, pubKey)
+	bondedCoins := bond.CoinsFromTokens(delegatorBond.BondedTokens)
+	if bondedCoins < uint64(amount.Amount) {
+		return fmt.Errorf("not enough bonded coins to unbond, have %v, trying to unbond %v",
+			bondedCoins, amount)
 	}
 	return nil
 }
@@ -181,20 +494,40 @@ func (h Handler) DeliverTx(ctx sdk.Context, store state.SimpleDB,
 		return res, abciRes
 	}
 
-	// get the holding account for the sender's bond.
-	// holding account is just an sdk.Actor, with the sender's address shifted one byte right.
+	// get the holding account for the sender's bond, derived from the sender
 	holder := getHoldAccount(sender)
 
 	// Run the transaction
 	switch _tx := tx.Unwrap().(type) {
+	case TxDeclareCandidacy:
+		fn := defaultTransferFn(ctx, store, dispatch)
+		abciRes = runTxDeclareCandidacy(store, sender, holder, fn, _tx)
+	case TxEditCandidacy:
+		abciRes = runTxEditCandidacy(store, sender, _tx)
 	case TxBond:
 		fn := defaultTransferFn(ctx, store, dispatch)
-		abciRes = runTxBond(store, sender, holder, fn, _tx)
+		abciRes = runTxBond(store, sender, fn, _tx)
 	case TxUnbond:
-		//context with hold account permissions
-		ctx2 := ctx.WithPermissions(holder)
-		fn := defaultTransferFn(ctx2, store, dispatch)
-		abciRes = runTxUnbond(store, sender, holder, fn, _tx)
+		abciRes = runTxUnbond(store, sender, ctx.BlockHeight(), _tx)
+	case TxDelegate:
+		fn := defaultTransferFn(ctx, store, dispatch)
+		abciRes = runTxDelegate(store, sender, fn, _tx)
+	case TxUnbondDelegation:
+		abciRes = runTxUnbondDelegation(store, sender, ctx.BlockHeight(), _tx)
+	case TxRedelegate:
+		// the coins are moved out of the hold account of the old validator
+		from := loadValidatorBondByPubKey(store, _tx.PubKeyFrom)
+		fn := defaultTransferFn(ctx.WithPermissions(from.HoldAccount), store, dispatch)
+		abciRes = runTxRedelegate(store, sender, ctx.BlockHeight(), fn, _tx)
+	case TxUnjail:
+		abciRes = runTxUnjail(store, sender, _tx)
+	case TxEditValidator:
+		abciRes = runTxEditValidator(store, sender, ctx.BlockHeight(), _tx)
+	}
+
+	// a failed tx returns an error, so its partial writes are discarded
+	if abciRes.IsErr() {
+		return res, abciRes
 	}
 
 	res = sdk.DeliverResult{
@@ -210,57 +543,307 @@ func (h Handler) DeliverTx(ctx sdk.Context, store state.SimpleDB,
 // these functions assume everything has been authenticated,
 // now we just bond or unbond and save
 
-func runTxBond(store state.SimpleDB, sender, holder sdk.Actor,
+func runTxDeclareCandidacy(store state.SimpleDB, sender, holder sdk.Actor,
+	transferFn transferFn, tx TxDeclareCandidacy) (res abci.Result) {
+
+	bond := NewValidatorBond(sender, holder, tx.PubKey)
+	bond.Description = tx.Description
+	bond.Commission = tx.Commission
+
+	// The validator's own tokens are held as a delegation to itself
+	res = delegate(store, sender, bond, transferFn, tx.Amount)
+	if res.IsErr() {
+		return res
+	}
+
+	saveValidatorBond(store, bond)
+	return abci.OK
+}
+
+func runTxEditCandidacy(store state.SimpleDB, sender sdk.Actor,
+	tx TxEditCandidacy) (res abci.Result) {
+
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return resNoValidatorForAddress
+	}
+
+	bond.Description = bond.Description.Update(tx.Description)
+	saveValidatorBond(store, bond)
+	return abci.OK
+}
+
+func runTxBond(store state.SimpleDB, sender sdk.Actor,
 	transferFn transferFn, tx TxBond) (res abci.Result) {
 
-	// Get amount of coins to bond
-	bondCoin := tx.Amount
+	// Get the candidate of this sender
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return resNoValidatorForAddress
+	}
+
+	// The validator's own tokens are held as a delegation to itself
+	res = delegate(store, sender, bond, transferFn, tx.Amount)
+	if res.IsErr() {
+		return res
+	}
+
+	saveValidatorBond(store, bond)
+	return abci.OK
+}
+
+func runTxUnbond(store state.SimpleDB, sender sdk.Actor,
+	height uint64, tx TxUnbond) (res abci.Result) {
 
-	// Get the validator bond accounts, and bond and index for this sender
-	bonds := LoadBonds(store)
-	idx, bond := bonds.Get(sender)
-	if bond == nil { //if it doesn't yet exist create it
-		bonds = bonds.Add(NewValidatorBond(sender, holder, tx.PubKey))
-		idx = len(bonds) - 1
+	//get validator bond
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return resNoValidatorForAddress
 	}
 
-	// Move coins from the sender account to the holder account
-	res = transferFn(sender, holder, coin.Coins{bondCoin})
+	res = unbond(store, sender, bond, height, tx.Amount)
 	if res.IsErr() {
 		return res
 	}
 
-	// Update the bond and save to store
-	bonds[idx].BondedTokens += uint64(bondCoin.Amount)
-	saveBonds(store, bonds)
+	saveValidatorBond(store, bond)
+	return abci.OK
+}
 
+func runTxDelegate(store state.SimpleDB, sender sdk.Actor,
+	transferFn transferFn, tx TxDelegate) (res abci.Result) {
+
+	bond := loadValidatorBondByPubKey(store, tx.PubKey)
+	if bond == nil {
+		return resBadValidatorAddr
+	}
+
+	res = delegate(store, sender, bond, transferFn, tx.Amount)
+	if res.IsErr() {
+		return res
+	}
+
+	saveValidatorBond(store, bond)
 	return abci.OK
 }
 
-func runTxUnbond(store state.SimpleDB, sender, holder sdk.Actor,
-	transferFn transferFn, tx TxUnbond) (res abci.Result) {
+func runTxUnbondDelegation(store state.SimpleDB, sender sdk.Actor,
+	height uint64, tx TxUnbondDelegation) (res abci.Result) {
 
-	//get validator bond
-	bonds := LoadBonds(store)
-	_, bond := bonds.Get(sender)
+	bond := loadValidatorBondByPubKey(store, tx.PubKey)
+	if bond == nil {
+		return resBadValidatorAddr
+	}
+
+	res = unbond(store, sender, bond, height, tx.Amount)
+	if res.IsErr() {
+		return res
+	}
+
+	saveValidatorBond(store, bond)
+	return abci.OK
+}
+
+func runTxRedelegate(store state.SimpleDB, sender sdk.Actor, height uint64,
+	transferFn transferFn, tx TxRedelegate) (res abci.Result) {
+
+	from := loadValidatorBondByPubKey(store, tx.PubKeyFrom)
+	to := loadValidatorBondByPubKey(store, tx.PubKeyTo)
+	if from == nil || to == nil {
+		return resBadValidatorAddr
+	}
+
+	// bond tokens of the new validator are issued at its exchange rate
+	amt := uint64(tx.Amount.Amount)
+	tokens, err := to.TokensFromCoins(amt, false)
+	if err != nil {
+		return abci.ErrBaseInvalidInput.AppendLog(err.Error())
+	}
+
+	// move the coins before any write, a failed transfer leaves the bonds as
+	// they were
+	res = transferFn(from.HoldAccount, to.HoldAccount, coin.Coins{tx.Amount})
+	if res.IsErr() {
+		return res
+	}
+	res = removeDelegation(store, sender, from, amt)
+	if res.IsErr() {
+		return res
+	}
+	addDelegation(store, sender, to, tokens, amt)
+
+	// the coins can still be slashed for faults of the old validator
+	// until the unbonding period has passed
+	pushRedelegationQueue(store, RedelegationQueueElem{
+		Delegator:     sender,
+		PubKeyFrom:    from.PubKey,
+		PubKeyTo:      to.PubKey,
+		Amount:        amt,
+		HeightRelease: height + loadParams(store).UnbondingPeriod,
+	})
+
+	saveValidatorBond(store, from)
+	saveValidatorBond(store, to)
+	return abci.OK
+}
+
+func runTxUnjail(store state.SimpleDB, sender sdk.Actor, tx TxUnjail) (res abci.Result) {
+	bond := loadValidatorBond(store, sender)
 	if bond == nil {
 		return resNoValidatorForAddress
 	}
 
-	// transfer coins back to account
-	unbondCoin := tx.Amount
-	unbondAmt := uint64(unbondCoin.Amount)
-	res = transferFn(holder, sender, coin.Coins{unbondCoin})
+	// the voting power is restored by the next UpdateVotingPower, and the
+	// missed blocks are counted from scratch
+	bond.Jailed = false
+	bond.JailedUntil = 0
+	removeSigningInfo(store, bond.PubKey)
+
+	saveValidatorBond(store, bond)
+	return abci.OK
+}
+
+func runTxEditValidator(store state.SimpleDB, sender sdk.Actor,
+	height uint64, tx TxEditValidator) (res abci.Result) {
+
+	bond := loadValidatorBond(store, sender)
+	if bond == nil {
+		return resNoValidatorForAddress
+	}
+
+	err := bond.EditCommission(tx.Commission, height, loadParams(store))
+	if err != nil {
+		return abci.ErrBaseInvalidInput.AppendLog(err.Error())
+	}
+
+	saveValidatorBond(store, bond)
+	return abci.OK
+}
+
+// delegate moves coins from the delegator to the validator's hold account and
+// credits the bond tokens to both the delegator bond and the validator bond.
+// The caller is responsible for saving the validator bond.
+func delegate(store state.SimpleDB, delegator sdk.Actor, bond *ValidatorBond,
+	transferFn transferFn, bondCoin coin.Coin) (res abci.Result) {
+
+	// Bond tokens are issued at the current exchange rate
+	bondAmt := uint64(bondCoin.Amount)
+	tokens, err := bond.TokensFromCoins(bondAmt, false)
+	if err != nil {
+		return abci.ErrBaseInvalidInput.AppendLog(err.Error())
+	}
+
+	// Move coins from the delegator account to the holder account
+	res = transferFn(delegator, bond.HoldAccount, coin.Coins{bondCoin})
 	if res.IsErr() {
 		return res
 	}
 
-	bond.BondedTokens -= unbondAmt
+	addDelegation(store, delegator, bond, tokens, bondAmt)
+	return abci.OK
+}
+
+// credit the bond tokens, worth the coins, to the delegator bond and the
+// validator bond
+func addDelegation(store state.SimpleDB, delegator sdk.Actor, bond *ValidatorBond,
+	tokens, coins uint64) {
+
+	delegatorBond := loadDelegatorBond(store, delegator, bond.PubKey)
+	if delegatorBond == nil {
+		delegatorBond = NewDelegatorBond(delegator, bond.PubKey)
+	}
 
-	saveBonds(store, bonds)
+	delegatorBond.BondedTokens += tokens
+	bond.BondedTokens += tokens
+	bond.BondedCoins += coins
+
+	saveDelegatorBond(store, delegatorBond)
+}
+
+// debit the bond tokens worth the coins from the delegator bond and the
+// validator bond, nothing is changed if the delegator has too few tokens
+func removeDelegation(store state.SimpleDB, delegator sdk.Actor, bond *ValidatorBond,
+	coins uint64) abci.Result {
+
+	delegatorBond := loadDelegatorBond(store, delegator, bond.PubKey)
+	if delegatorBond == nil {
+		return resNoDelegatorForAddress
+	}
+
+	// round up so that the delegator never receives more than it holds
+	tokens, err := bond.TokensFromCoins(coins, true)
+	if err != nil || delegatorBond.BondedTokens < tokens {
+		return resInsufficientFunds
+	}
+
+	delegatorBond.BondedTokens -= tokens
+	bond.BondedTokens -= tokens
+	bond.BondedCoins -= coins
+
+	// remove the delegator bond once there is nothing left in it
+	if delegatorBond.BondedTokens == 0 {
+		removeDelegatorBond(store, delegator, bond.PubKey)
+	} else {
+		saveDelegatorBond(store, delegatorBond)
+	}
+	return abci.OK
+}
+
+// unbond debits the bond tokens worth the coins from the delegator bond and the
+// validator bond and places the coins in the unbonding queue. The coins stay in
+// the validator's hold account until they are released by ProcessUnbondingQueue.
+// The caller is responsible for saving the validator bond.
+func unbond(store state.SimpleDB, delegator sdk.Actor, bond *ValidatorBond,
+	height uint64, unbondCoin coin.Coin) (res abci.Result) {
+
+	unbondAmt := uint64(unbondCoin.Amount)
+	res = removeDelegation(store, delegator, bond, unbondAmt)
+	if res.IsErr() {
+		return res
+	}
+
+	pushUnbondingQueue(store, UnbondingQueueElem{
+		Delegator:     delegator,
+		PubKey:        bond.PubKey,
+		HoldAccount:   bond.HoldAccount,
+		Amount:        unbondAmt,
+		HeightRelease: height + loadParams(store).UnbondingPeriod,
+	})
 	return abci.OK
 }
 
+// ProcessUnbondingQueue - return all unbonding coins whose unbonding period
+// has passed at this height from the hold accounts to their delegators.
+// The coins are moved directly within coinStore, the store of the coin module.
+func ProcessUnbondingQueue(store, coinStore state.SimpleDB, height uint64) error {
+	return processUnbondingQueue(store, storeTransferFn(coinStore), height)
+}
+
+// separated for testing
+func processUnbondingQueue(store state.SimpleDB, transferFn transferFn, height uint64) error {
+	denom := loadParams(store).AllowedBondDenom
+	for _, elem := range loadUnbondingQueue(store, height) {
+		// the whole unbonding may have been slashed away
+		if elem.Amount > 0 {
+			releaseCoin := coin.Coin{denom, int64(elem.Amount)}
+			res := transferFn(elem.HoldAccount, elem.Delegator, coin.Coins{releaseCoin})
+			if res.IsErr() {
+				return res
+			}
+		}
+		removeUnbondingQueueElem(store, elem)
+	}
+	return nil
+}
+
+// ProcessRedelegationQueue - stop tracking the redelegations whose coins can no
+// longer be slashed for the validator they were redelegated from at this height
+func ProcessRedelegationQueue(store state.SimpleDB, height uint64) {
+	for _, elem := range loadRedelegationQueue(store, height) {
+		removeRedelegationQueueElem(store, elem)
+	}
+}
+
 // get the sender from the ctx and ensure it matches the tx pubkey
 func getTxSender(ctx sdk.Context) (sender sdk.Actor, res abci.Result) {
 	senders := ctx.GetPermissions("", auth.NameSigs)
@@ -268,20 +851,26 @@ func getTxSender(ctx sdk.Context) (sender sdk.Actor, res abci.Result) {
 		return sender, resMissingSignature
 	}
 
-	// TODO: ensure senders[0] matches tx.pubkey ...
-	// NOTE on TODO..  right now the PubKey doesn't need to match the sender
-	// and we actually don't have the means to construct the priv_validator.json
-	// with its private key with current keys tooling in SDK so needs to be
-	// a second key... This is still secure because you will only be able to
-	// unbond to the first married account, although, you could hypotheically
-	// bond some coins to somebody elses account (effectively giving them coins)
-	// maybe that is worth checking more. Validators should probably be allowed
-	// to use two different keys, one for validating and one with coins on it...
-	// so this point may never be relevant
+	// NOTE the validator pubkey of a tx doesn't need to match the sender,
+	// validators use one key for validating and one with coins on it. The
+	// sender proves it controls the validator key with the signature of
+	// TxDeclareCandidacy, see checkPossession
 	return senders[0], abci.OK
 }
 
+// the hold account of the validator declared by the sender
 func getHoldAccount(sender sdk.Actor) sdk.Actor {
-	holdAddr := append([]byte{0x00}, sender.Address[1:]...) //shift and prepend a zero
-	return sdk.NewActor(stakingModuleName, holdAddr)
+	return ModuleAccount(stakingModuleName, wire.BinaryBytes(&sender))
+}
+
+// ModuleAccount - an account controlled by a module on behalf of an identity,
+// such as the sender of a validator. The address is the hash of the module
+// name and the identity, so the accounts of different identities never share
+// an address.
+func ModuleAccount(module string, identity []byte) sdk.Actor {
+	hasher := sha256.New()
+	hasher.Write([]byte(module))
+	hasher.Write([]byte{0x00}) // the name can't run into the identity
+	hasher.Write(identity)
+	return sdk.NewActor(module, hasher.Sum(nil)[:20])
 }
@@ -1,14 +1,19 @@
 package stake
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	abci "github.com/tendermint/abci/types"
+	crypto "github.com/tendermint/go-crypto"
 
 	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
 	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/stack"
 	"github.com/cosmos/cosmos-sdk/state"
 )
 
@@ -29,6 +34,14 @@ func initAccounts(n int, amount int64) ([]sdk.Actor, map[string]int64) {
 	return senders, accStore
 }
 
+func newTxDeclareCandidacy(amt int64, pubKey string) TxDeclareCandidacy {
+	return TxDeclareCandidacy{
+		Amount:      coin.Coin{"fermion", amt},
+		PubKey:      []byte(pubKey),
+		Description: NewDescription("moniker", "", "", ""),
+	}
+}
+
 func newTxBond(amt int64) TxBond {
 	return TxBond{
 		Amount: coin.Coin{"fermion", amt},
@@ -41,7 +54,7 @@ func newTxUnbond(amt int64) TxUnbond {
 	}
 }
 
-func TestBondTxDuplicatePubKey(t *testing.T) {
+func TestDeclareCandidacyTxDuplicatePubKey(t *testing.T) {
 	assert := assert.New(t)
 
 	store := state.NewMemKVStore() // for bonds
@@ -50,26 +63,112 @@ func TestBondTxDuplicatePubKey(t *testing.T) {
 	sender, sender2 := senders[0], senders[1]
 	holder := getHoldAccount(sender)
 
-	bondAmount := int64(10)
-	txBond := newTxBond(bondAmount)
+	txDeclare := newTxDeclareCandidacy(10, "pubkey1")
+	err := checkCandidacy(txDeclare, sender, store)
+	assert.Nil(err, "expected no error on checkCandidacy")
+	got := runTxDeclareCandidacy(store, sender, holder, dummyTransferFn(accStore), txDeclare)
+	assert.Equal(got, abci.OK, "expected no error on runTxDeclareCandidacy")
 
-	txBond.PubKey = []byte("pubkey1")
-	got := runTxBond(store, sender, holder, dummyTransferFn(accStore), txBond)
-	assert.Equal(got, abci.OK, "expected no error on runTxBond")
+	// one sender can only declare one candidate
+	txDeclare.PubKey = []byte("pubkey2")
+	err = checkCandidacy(txDeclare, sender, store)
+	assert.Equal(errCandidateExistsAddr, err)
 
-	// one sender can bond to different pubkeys
+	// two senders cant declare the same pubkey
+	txDeclare.PubKey = []byte("pubkey1")
+	err = checkCandidacy(txDeclare, sender2, store)
+	assert.NotNil(err, "expected error on checkTx")
+
+	// bonding is only possible to the sender's own candidate
+	txBond := newTxBond(10)
+	txBond.PubKey = []byte("pubkey1")
+	assert.Nil(checkTxBond(txBond, sender, "testChain", store))
+	assert.Equal(errNoCandidateForAddress, checkTxBond(txBond, sender2, "testChain", store))
 	txBond.PubKey = []byte("pubkey2")
-	err := checkTxBond(txBond, sender, store)
-	assert.Nil(err, "expected no error on checkTx")
+	assert.NotNil(checkTxBond(txBond, sender, "testChain", store))
+}
 
-	// execute the last tx
-	got = runTxBond(store, sender, holder, dummyTransferFn(accStore), txBond)
-	assert.Equal(got, abci.OK, "expected no error on runTxBond")
+func TestProofOfPossession(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
 
-	// two senders cant bond to the same pubkey
-	txBond.PubKey = []byte("pubkey1")
-	err = checkTxBond(txBond, sender2, store)
-	assert.NotNil(err, "expected error on checkTx")
+	store := state.NewMemKVStore()
+	senders, accStore := initAccounts(2, 1000)
+	sender, squatter := senders[0], senders[1]
+
+	privKey := crypto.GenPrivKeyEd25519()
+	pubKey := privKey.PubKey().Bytes()
+	txDeclare := newTxDeclareCandidacy(100, "")
+	txDeclare.PubKey = pubKey
+
+	// the signature is required
+	assert.Equal(errMissingPossessionSig, checkTxDeclareCandidacy(txDeclare, squatter,
+		"testChain", store))
+
+	// it must be the sender signed by the validator key, for this chain
+	txDeclare.Signature = privKey.Sign(PossessionSignBytes("testChain", sender)).Bytes()
+	assert.Equal(errBadPossessionSig, checkTxDeclareCandidacy(txDeclare, squatter,
+		"testChain", store))
+	assert.Equal(errBadPossessionSig, checkTxDeclareCandidacy(txDeclare, sender,
+		"otherChain", store))
+	otherKey := crypto.GenPrivKeyEd25519()
+	txDeclare.Signature = otherKey.Sign(PossessionSignBytes("testChain", sender)).Bytes()
+	assert.Equal(errBadPossessionSig, checkTxDeclareCandidacy(txDeclare, sender,
+		"testChain", store))
+	txDeclare.Signature = []byte("not a signature")
+	assert.Equal(errBadPossessionSig, checkTxDeclareCandidacy(txDeclare, sender,
+		"testChain", store))
+
+	// a signature of the bare address, made for another purpose, isn't valid
+	txDeclare.Signature = privKey.Sign(sender.Address).Bytes()
+	assert.Equal(errBadPossessionSig, checkTxDeclareCandidacy(txDeclare, sender,
+		"testChain", store))
+
+	txDeclare.Signature = privKey.Sign(PossessionSignBytes("testChain", sender)).Bytes()
+	require.Nil(checkTxDeclareCandidacy(txDeclare, sender, "testChain", store))
+	got := runTxDeclareCandidacy(store, sender, getHoldAccount(sender),
+		dummyTransferFn(accStore), txDeclare)
+	require.True(got.IsOK(), "expected declare candidacy tx to be ok, got %v", got)
+
+	// bonding more checks the optional signature against the pubkey of the
+	// candidate
+	txBond := newTxBond(10)
+	assert.Nil(checkTxBond(txBond, sender, "testChain", store))
+	txBond.Signature = otherKey.Sign(PossessionSignBytes("testChain", sender)).Bytes()
+	assert.Equal(errBadPossessionSig, checkTxBond(txBond, sender, "testChain", store))
+	txBond.Signature = privKey.Sign(PossessionSignBytes("testChain", sender)).Bytes()
+	assert.Nil(checkTxBond(txBond, sender, "testChain", store))
+}
+
+func TestEditCandidacy(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	senders, accStore := initAccounts(2, 1000)
+	sender := senders[0]
+
+	txDeclare := newTxDeclareCandidacy(10, "pubkey1")
+	txDeclare.Description = NewDescription("moniker", "keybase", "example.com", "")
+	got := runTxDeclareCandidacy(store, sender, getHoldAccount(sender), dummyTransferFn(accStore), txDeclare)
+	require.True(got.IsOK())
+
+	// a moniker is required
+	txDeclare.Description.Moniker = ""
+	assert.Equal(errCandidateEmptyMoniker, txDeclare.ValidateBasic())
+
+	// only the non-empty fields are edited
+	txEdit := TxEditCandidacy{NewDescription("", "", "", "details")}
+	assert.Nil(txEdit.ValidateBasic())
+	assert.Equal(errNoCandidateForAddress, checkTxEditCandidacy(txEdit, senders[1], store))
+	require.Nil(checkTxEditCandidacy(txEdit, sender, store))
+	require.True(runTxEditCandidacy(store, sender, txEdit).IsOK())
+
+	_, bond := LoadBonds(store).Get(sender)
+	assert.Equal(NewDescription("moniker", "keybase", "example.com", "details"), bond.Description)
+
+	// the fields can't be too long
+	txEdit.Description.Website = string(make([]byte, MaxWebsiteLength+1))
+	assert.NotNil(checkTxEditCandidacy(txEdit, sender, store))
+	assert.NotNil(TxEditCandidacy{}.ValidateBasic())
 }
 
 func TestBondTxIncrements(t *testing.T) {
@@ -81,11 +180,15 @@ func TestBondTxIncrements(t *testing.T) {
 	sender := senders[0]
 	holder := getHoldAccount(sender)
 
-	// just send the same txbond multiple times
+	// declare the candidate, then send the same txbond multiple times
 	bondAmount := int64(10)
+	txDeclare := newTxDeclareCandidacy(bondAmount, "pubkey")
+	got := runTxDeclareCandidacy(store, sender, holder, dummyTransferFn(accStore), txDeclare)
+	assert.True(got.IsOK(), "expected declare tx to be ok, got %v", got)
+
 	txBond := newTxBond(bondAmount)
-	for i := 0; i < 5; i++ {
-		got := runTxBond(store, sender, holder, dummyTransferFn(accStore), txBond)
+	for i := 1; i < 5; i++ {
+		got := runTxBond(store, sender, dummyTransferFn(accStore), txBond)
 		assert.True(got.IsOK(), "expected tx %d to be ok, got %v", i, got)
 
 		//Check that the accounts and the bond account have the appropriate values
@@ -114,28 +217,42 @@ func TestUnbondTxIncrements(t *testing.T) {
 	// set initial bond
 	initBond := int64(1000)
 	accStore[string(sender.Address)] = initBond
-	got := runTxBond(store, sender, holder, dummyTransferFn(accStore), newTxBond(initBond))
+	txDeclare := newTxDeclareCandidacy(initBond, "pubkey")
+	got := runTxDeclareCandidacy(store, sender, holder, dummyTransferFn(accStore), txDeclare)
 	assert.True(got.IsOK(), "expected initial bond tx to be ok, got %v", got)
 
 	// just send the same txunbond multiple times
 	unbondAmount := int64(10)
 	txUnbond := newTxUnbond(unbondAmount)
 	for i := 0; i < 5; i++ {
-		got := runTxUnbond(store, sender, holder, dummyTransferFn(accStore), txUnbond)
+		got := runTxUnbond(store, sender, 0, txUnbond)
 		assert.True(got.IsOK(), "expected tx %d to be ok, got %v", i, got)
 
-		//Check that the accounts and the bond account have the appropriate values
+		//Check that the bond is reduced but the coins are still held
 		validators := LoadBonds(store)
 		expectedBond := initBond - int64(i+1)*unbondAmount // +1 since we send 1 at the start of loop
-		expectedSender := initSender + (initBond - expectedBond)
 		gotBonded := int64(validators[0].BondedTokens)
 		gotHolder := accStore[string(holder.Address)]
 		gotSender := accStore[string(sender.Address)]
 
 		assert.Equal(expectedBond, gotBonded, "%v, %v", expectedBond, gotBonded)
-		assert.Equal(expectedBond, gotHolder, "%v, %v", expectedBond, gotHolder)
-		assert.Equal(expectedSender, gotSender, "%v, %v", expectedSender, gotSender)
+		assert.Equal(initBond, gotHolder, "%v, %v", initBond, gotHolder)
+		assert.Equal(initSender, gotSender, "%v, %v", initSender, gotSender)
 	}
+
+	// nothing is released before the unbonding period has passed
+	unbondingPeriod := loadParams(store).UnbondingPeriod
+	err := processUnbondingQueue(store, dummyTransferFn(accStore), unbondingPeriod-1)
+	assert.Nil(err)
+	assert.Equal(initBond, accStore[string(holder.Address)])
+
+	// then all the unbonded coins are returned at once
+	err = processUnbondingQueue(store, dummyTransferFn(accStore), unbondingPeriod)
+	assert.Nil(err)
+	expectedBond := initBond - 5*unbondAmount
+	assert.Equal(expectedBond, accStore[string(holder.Address)])
+	assert.Equal(initSender+5*unbondAmount, accStore[string(sender.Address)])
+	assert.Empty(loadUnbondingQueue(store, unbondingPeriod))
 }
 
 func TestBondTxMultipleVals(t *testing.T) {
@@ -147,8 +264,8 @@ func TestBondTxMultipleVals(t *testing.T) {
 
 	// bond them all
 	for i, sender := range senders {
-		txBond := newTxBond(int64(i))
-		got := runTxBond(store, sender, getHoldAccount(sender), dummyTransferFn(accStore), txBond)
+		txDeclare := newTxDeclareCandidacy(int64(i), fmt.Sprintf("pubkey%d", i))
+		got := runTxDeclareCandidacy(store, sender, getHoldAccount(sender), dummyTransferFn(accStore), txDeclare)
 		assert.True(got.IsOK(), "expected tx %d to be ok, got %v", i, got)
 
 		//Check that the account is bonded
@@ -163,8 +280,10 @@ func TestBondTxMultipleVals(t *testing.T) {
 	// unbond them all
 	for i, sender := range senders {
 		txUnbond := newTxUnbond(int64(i))
-		got := runTxUnbond(store, sender, getHoldAccount(sender), dummyTransferFn(accStore), txUnbond)
+		got := runTxUnbond(store, sender, 0, txUnbond)
 		assert.True(got.IsOK(), "expected tx %d to be ok, got %v", i, got)
+		err := processUnbondingQueue(store, dummyTransferFn(accStore), loadParams(store).UnbondingPeriod)
+		assert.Nil(err)
 
 		// Check that the account is unbonded
 		validators := LoadBonds(store)
@@ -177,3 +296,229 @@ func TestBondTxMultipleVals(t *testing.T) {
 		assert.Equal(balanceGot, balanceExpect, "expected account to have %d, got %d", balanceExpect, balanceGot)
 	}
 }
+
+func TestDelegateTxAndUnbondDelegation(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	initSender := int64(1000)
+	senders, accStore := initAccounts(2, initSender)
+	validator, delegator := senders[0], senders[1]
+	holder := getHoldAccount(validator)
+
+	// the validator bonds to itself first
+	txBond := newTxDeclareCandidacy(100, "pubkey1")
+	got := runTxDeclareCandidacy(store, validator, holder, dummyTransferFn(accStore), txBond)
+	require.True(got.IsOK(), "expected bond tx to be ok, got %v", got)
+
+	// can't delegate to a pubkey which isn't a validator
+	txDelegate := TxDelegate{Amount: coin.Coin{"fermion", 50}, PubKey: []byte("pubkey2")}
+	assert.NotNil(checkTxDelegate(txDelegate, store))
+
+	// delegate to the validator, the coins are held by the validator's holder
+	txDelegate.PubKey = txBond.PubKey
+	assert.Nil(checkTxDelegate(txDelegate, store))
+	got = runTxDelegate(store, delegator, dummyTransferFn(accStore), txDelegate)
+	require.True(got.IsOK(), "expected delegate tx to be ok, got %v", got)
+
+	validators := LoadBonds(store)
+	delegatorBond := loadDelegatorBond(store, delegator, txBond.PubKey)
+	require.NotNil(delegatorBond)
+	assert.Equal(uint64(150), validators[0].BondedTokens)
+	assert.Equal(uint64(50), delegatorBond.BondedTokens)
+	assert.Equal(int64(150), accStore[string(holder.Address)])
+	assert.Equal(initSender-50, accStore[string(delegator.Address)])
+
+	// the validator can't unbond the delegated tokens
+	assert.NotNil(checkTxUnbond(newTxUnbond(150), validator, store))
+	assert.Nil(checkTxUnbond(newTxUnbond(100), validator, store))
+
+	// the delegator can only unbond what it delegated
+	txUnbond := TxUnbondDelegation{Amount: coin.Coin{"fermion", 60}, PubKey: txBond.PubKey}
+	assert.NotNil(checkTxUnbondDelegation(txUnbond, delegator, store))
+	txUnbond.Amount.Amount = 50
+	assert.Nil(checkTxUnbondDelegation(txUnbond, delegator, store))
+
+	got = runTxUnbondDelegation(store, delegator, 0, txUnbond)
+	require.True(got.IsOK(), "expected unbond delegation tx to be ok, got %v", got)
+	err := processUnbondingQueue(store, dummyTransferFn(accStore), loadParams(store).UnbondingPeriod)
+	require.Nil(err)
+
+	validators = LoadBonds(store)
+	assert.Equal(uint64(100), validators[0].BondedTokens)
+	assert.Nil(loadDelegatorBond(store, delegator, txBond.PubKey))
+	assert.Equal(int64(100), accStore[string(holder.Address)])
+	assert.Equal(initSender, accStore[string(delegator.Address)])
+}
+
+func TestRedelegate(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	senders, accStore := initAccounts(3, 1000)
+	delegator := senders[2]
+	holderFrom, holderTo := getHoldAccount(senders[0]), getHoldAccount(senders[1])
+
+	txFrom := newTxDeclareCandidacy(100, "pubkey1")
+	got := runTxDeclareCandidacy(store, senders[0], holderFrom, dummyTransferFn(accStore), txFrom)
+	require.True(got.IsOK(), "expected declare candidacy tx to be ok, got %v", got)
+	txTo := newTxDeclareCandidacy(100, "pubkey2")
+	got = runTxDeclareCandidacy(store, senders[1], holderTo, dummyTransferFn(accStore), txTo)
+	require.True(got.IsOK(), "expected declare candidacy tx to be ok, got %v", got)
+
+	txDelegate := TxDelegate{Amount: coin.Coin{"fermion", 100}, PubKey: txFrom.PubKey}
+	got = runTxDelegate(store, delegator, dummyTransferFn(accStore), txDelegate)
+	require.True(got.IsOK(), "expected delegate tx to be ok, got %v", got)
+
+	// only delegated coins can be redelegated, to an existing validator
+	txRedelegate := TxRedelegate{coin.Coin{"fermion", 60}, txFrom.PubKey, txTo.PubKey}
+	assert.Equal(errRedelegateSameValidator,
+		TxRedelegate{coin.Coin{"fermion", 60}, txFrom.PubKey, txFrom.PubKey}.ValidateBasic())
+	assert.NotNil(checkTxRedelegate(TxRedelegate{coin.Coin{"fermion", 60},
+		txFrom.PubKey, []byte("pubkey3")}, delegator, store))
+	assert.NotNil(checkTxRedelegate(TxRedelegate{coin.Coin{"fermion", 101},
+		txFrom.PubKey, txTo.PubKey}, delegator, store))
+	require.Nil(checkTxRedelegate(txRedelegate, delegator, store))
+
+	// a failed transfer leaves the bonds untouched
+	height := uint64(10)
+	failTransferFn := func(from, to sdk.Actor, coins coin.Coins) abci.Result {
+		return abci.ErrInsufficientFunds
+	}
+	got = runTxRedelegate(store, delegator, height, failTransferFn, txRedelegate)
+	assert.True(got.IsErr())
+	assert.Equal(uint64(100), loadDelegatorBond(store, delegator, txFrom.PubKey).BondedTokens)
+	assert.Nil(loadDelegatorBond(store, delegator, txTo.PubKey))
+	assert.Equal(uint64(200), loadValidatorBond(store, senders[0]).BondedCoins)
+
+	// the coins move between the hold accounts without unbonding
+	got = runTxRedelegate(store, delegator, height, dummyTransferFn(accStore), txRedelegate)
+	require.True(got.IsOK(), "expected redelegate tx to be ok, got %v", got)
+	assert.Empty(loadFullUnbondingQueue(store))
+	assert.Equal(uint64(140), loadValidatorBond(store, senders[0]).BondedCoins)
+	assert.Equal(uint64(160), loadValidatorBond(store, senders[1]).BondedCoins)
+	assert.Equal(int64(140), accStore[string(holderFrom.Address)])
+	assert.Equal(int64(160), accStore[string(holderTo.Address)])
+	assert.Equal(uint64(40), loadDelegatorBond(store, delegator, txFrom.PubKey).BondedTokens)
+	assert.Equal(uint64(60), loadDelegatorBond(store, delegator, txTo.PubKey).BondedTokens)
+
+	// the redelegated coins can't be moved on while they can still be slashed
+	txBack := TxRedelegate{coin.Coin{"fermion", 10}, txTo.PubKey, txFrom.PubKey}
+	assert.Equal(errRedelegationMaturing, checkTxRedelegate(txBack, delegator, store))
+
+	// a slash of the old validator reaches the redelegated coins
+	err := slash(store, dummyChangeCoinsFn(accStore), txFrom.PubKey, NewFraction(1, 10))
+	require.Nil(err)
+	assert.Equal(uint64(126), loadValidatorBond(store, senders[0]).BondedCoins)
+	assert.Equal(uint64(154), loadValidatorBond(store, senders[1]).BondedCoins)
+	assert.Equal(int64(154), accStore[string(holderTo.Address)])
+	assert.Equal(uint64(54), loadDelegatorBond(store, delegator, txTo.PubKey).BondedTokens)
+	queue := loadFullRedelegationQueue(store)
+	require.Equal(1, len(queue))
+	assert.Equal(uint64(54), queue[0].Amount)
+
+	// once the unbonding period has passed the redelegation is forgotten
+	ProcessRedelegationQueue(store, height+loadParams(store).UnbondingPeriod-1)
+	assert.Equal(1, len(loadFullRedelegationQueue(store)))
+	ProcessRedelegationQueue(store, height+loadParams(store).UnbondingPeriod)
+	assert.Empty(loadFullRedelegationQueue(store))
+	assert.Nil(checkTxRedelegate(txBack, delegator, store))
+}
+
+// a dispatcher whose transfers always fail
+type failingDeliver struct{}
+
+func (failingDeliver) DeliverTx(ctx sdk.Context, store state.SimpleDB,
+	tx sdk.Tx) (sdk.DeliverResult, error) {
+
+	return sdk.DeliverResult{}, fmt.Errorf("insufficient funds")
+}
+
+func TestDeliverTxError(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	senders, accStore := initAccounts(2, 1000)
+	txDeclare := newTxDeclareCandidacy(100, "pubkey1")
+	got := runTxDeclareCandidacy(store, senders[0], getHoldAccount(senders[0]),
+		dummyTransferFn(accStore), txDeclare)
+	require.True(got.IsOK(), "expected declare candidacy tx to be ok, got %v", got)
+
+	// a tx whose transfer fails returns an error, so the app discards its writes
+	delegator := auth.SigPerm(senders[1].Address)
+	ctx := stack.MockContext("testChain", 10).WithPermissions(delegator)
+	tx := TxDelegate{Amount: coin.Coin{"fermion", 100}, PubKey: txDeclare.PubKey}.Wrap()
+	_, err := Handler{}.DeliverTx(ctx, store, tx, failingDeliver{})
+	assert.NotNil(err)
+	assert.Nil(loadDelegatorBond(store, delegator, txDeclare.PubKey))
+}
+
+func TestEditValidatorCommission(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	senders, accStore := initAccounts(2, 1000)
+	sender := senders[0]
+	params := defaultParams()
+	params.BlocksPerYear = 3650 // 10 blocks a day
+	saveParams(store, params)
+
+	// the commission is set when the validator is created
+	txDeclare := newTxDeclareCandidacy(100, "pubkey1")
+	txDeclare.Commission = NewCommission(NewFraction(1, 10), NewFraction(3, 10), NewFraction(1, 10))
+	got := runTxDeclareCandidacy(store, sender, getHoldAccount(sender), dummyTransferFn(accStore), txDeclare)
+	require.True(got.IsOK())
+
+	// only existing validators can be edited
+	txEdit := TxEditValidator{NewFraction(2, 10)}
+	assert.Equal(resNoValidatorForAddress, checkTxEditValidator(txEdit, senders[1], 1, store))
+
+	// the rate can change by the max change, and not above the max
+	assert.NotNil(checkTxEditValidator(TxEditValidator{NewFraction(25, 100)}, sender, 1, store))
+	assert.NotNil(checkTxEditValidator(TxEditValidator{NewFraction(35, 100)}, sender, 1, store))
+	require.Nil(checkTxEditValidator(txEdit, sender, 1, store))
+	require.True(runTxEditValidator(store, sender, 1, txEdit).IsOK())
+	_, bond := LoadBonds(store).Get(sender)
+	assert.Equal(NewFraction(2, 10), bond.Commission.Rate)
+
+	// and only once a day
+	txEdit = TxEditValidator{NewFraction(3, 10)}
+	assert.NotNil(checkTxEditValidator(txEdit, sender, 10, store))
+	assert.False(runTxEditValidator(store, sender, 10, txEdit).IsOK())
+	require.Nil(checkTxEditValidator(txEdit, sender, 11, store))
+}
+
+func TestHoldAccountCollision(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	// the senders only differ by the first byte of their address, which the
+	// hold accounts of older chains dropped
+	store := state.NewMemKVStore()
+	senders := []sdk.Actor{sdk.NewActor("sigs", []byte("1address")), sdk.NewActor("sigs", []byte("2address"))}
+	accStore := map[string]int64{"1address": 1000, "2address": 1000}
+	assert.Equal(oldHoldAccount(senders[0]), oldHoldAccount(senders[1]))
+	holders := []sdk.Actor{getHoldAccount(senders[0]), getHoldAccount(senders[1])}
+	assert.NotEqual(holders[0], holders[1])
+	assert.Equal(stakingModuleName, holders[0].App)
+	assert.Equal(20, len(holders[0].Address))
+
+	got := runTxDeclareCandidacy(store, senders[0], holders[0], dummyTransferFn(accStore),
+		newTxDeclareCandidacy(100, "pubkey1"))
+	require.True(got.IsOK(), "expected declare candidacy tx to be ok, got %v", got)
+	got = runTxDeclareCandidacy(store, senders[1], holders[1], dummyTransferFn(accStore),
+		newTxDeclareCandidacy(200, "pubkey2"))
+	require.True(got.IsOK(), "expected declare candidacy tx to be ok, got %v", got)
+
+	// the first validator only unbonds from its own hold account
+	got = runTxUnbond(store, senders[0], 0, newTxUnbond(100))
+	require.True(got.IsOK(), "expected unbond tx to be ok, got %v", got)
+	require.Nil(processUnbondingQueue(store, dummyTransferFn(accStore), loadParams(store).UnbondingPeriod))
+	assert.Equal(int64(1000), accStore["1address"])
+	assert.Equal(int64(0), accStore[string(holders[0].Address)])
+	assert.Equal(int64(200), accStore[string(holders[1].Address)])
+
+	// module accounts are distinct across modules and identities
+	assert.NotEqual(ModuleAccount("stake", []byte("a")), ModuleAccount("gov", []byte("a")))
+	assert.NotEqual(ModuleAccount("stake", []byte("a")), ModuleAccount("stake", []byte("b")))
+	assert.NotEqual(ModuleAccount("stakea", nil).Address, ModuleAccount("stake", []byte("a")).Address)
+}
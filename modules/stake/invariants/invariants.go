@@ -0,0 +1,232 @@
+package invariants
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tendermint/go-wire"
+
+	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/stack"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+// account - the coins of an account of the coin module
+type account struct {
+	Actor sdk.Actor
+	Coins coin.Coins
+}
+
+// appState - the staking state, read as it would be exported, and all the
+// accounts of the coin module
+type appState struct {
+	stake.Genesis
+	Accounts []account
+}
+
+// an invariant returns an error describing how the state breaks it
+type invariant func(s appState) error
+
+// the invariants which hold after each transaction, in the order they are
+// checked
+var txInvariants = []invariant{
+	supplyConserved,
+	holdAccountsBalanced,
+	uniquePubKeys, // the delegations are matched to the validators by pubkey
+	tokensConserved,
+	sortedPower,
+}
+
+// all the invariants, the empty bonds are only removed by the tick
+var invariants = append(txInvariants, noEmptyBonds)
+
+// Check - check the invariants of the staking state within store, the store of
+// the app with the store of each module under its prefix. It is meant to run
+// at the end of the tick, once the empty bonds have been cleaned up. Returns an
+// error describing the first broken invariant.
+func Check(store state.SimpleDB) error {
+	return check(store, invariants)
+}
+
+// CheckAfterTx - check the invariants which hold between the transactions of a
+// block, all but the removal of the bonds left without tokens
+func CheckAfterTx(store state.SimpleDB) error {
+	return check(store, txInvariants)
+}
+
+func check(store state.SimpleDB, invariants []invariant) error {
+	s := appState{
+		Genesis:  stake.ExportGenesis(stack.PrefixedStore(stake.Name(), store), nil, 0),
+		Accounts: loadAccounts(store),
+	}
+	for _, inv := range invariants {
+		err := inv(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load all the accounts of the coin module
+func loadAccounts(store state.SimpleDB) (accounts []account) {
+	prefix := stack.PrefixedKey(coin.NameCoin, nil)
+	for _, model := range store.List(prefix, prefixEnd(prefix), 0) {
+		var actor sdk.Actor
+		var acc coin.Account
+		if wire.ReadBinaryBytes(model.Key[len(prefix):], &actor) != nil ||
+			wire.ReadBinaryBytes(model.Value, &acc) != nil {
+			continue // not an account
+		}
+		accounts = append(accounts, account{actor, acc.Coins})
+	}
+	return
+}
+
+// the end of the range of the keys starting with the prefix
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// the amount of the denom within the coins
+func amountOf(coins coin.Coins, denom string) int64 {
+	for _, c := range coins {
+		if c.Denom == denom {
+			return c.Amount
+		}
+	}
+	return 0
+}
+
+// the supply of the bond denom tracked for the provisions is the sum of the
+// coins held by all the accounts, once it is set in the genesis
+func supplyConserved(s appState) error {
+	// until the first provisions the supply is the one of the genesis
+	totalSupply := s.Params.TotalSupply
+	if s.Provisions != nil {
+		totalSupply = s.Provisions.TotalSupply
+	}
+	if totalSupply == 0 {
+		return nil
+	}
+
+	var supply int64
+	for _, acc := range s.Accounts {
+		supply += amountOf(acc.Coins, s.Params.AllowedBondDenom)
+	}
+	if supply != int64(totalSupply) {
+		return fmt.Errorf("supply: the accounts hold %d%s, the tracked supply is %d",
+			supply, s.Params.AllowedBondDenom, totalSupply)
+	}
+	return nil
+}
+
+// each hold account holds the coins bonded to its validator and the coins
+// still unbonding from it, no more and no less
+func holdAccountsBalanced(s appState) error {
+	holders := make(map[string]sdk.Actor)
+	held, expected := make(map[string]int64), make(map[string]int64)
+	for _, acc := range s.Accounts {
+		if acc.Actor.App == stake.Name() {
+			key := string(acc.Actor.Bytes())
+			holders[key] = acc.Actor
+			held[key] = amountOf(acc.Coins, s.Params.AllowedBondDenom)
+		}
+	}
+	for _, bond := range s.Bonds {
+		key := string(bond.HoldAccount.Bytes())
+		holders[key] = bond.HoldAccount
+		expected[key] += int64(bond.BondedCoins)
+	}
+	for _, elem := range s.UnbondingQueue {
+		key := string(elem.HoldAccount.Bytes())
+		holders[key] = elem.HoldAccount
+		expected[key] += int64(elem.Amount)
+	}
+
+	keys := make([]string, 0, len(holders))
+	for key := range holders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if held[key] != expected[key] {
+			return fmt.Errorf("hold account %X: holds %d%s, %d are bonded or unbonding",
+				holders[key].Address, held[key], s.Params.AllowedBondDenom, expected[key])
+		}
+	}
+	return nil
+}
+
+// the bond tokens of each validator are the sum of the tokens of its
+// delegators, and delegations are only to existing validators
+func tokensConserved(s appState) error {
+	delegated := make(map[string]uint64)
+	for _, bond := range s.Delegations {
+		delegated[string(bond.PubKey)] += bond.BondedTokens
+	}
+
+	for _, bond := range s.Bonds {
+		if tokens := delegated[string(bond.PubKey)]; tokens != bond.BondedTokens {
+			return fmt.Errorf("validator %X: has %d bond tokens, its delegators %d",
+				bond.PubKey, bond.BondedTokens, tokens)
+		}
+		delete(delegated, string(bond.PubKey))
+	}
+	for _, bond := range s.Delegations {
+		if tokens, ok := delegated[string(bond.PubKey)]; ok {
+			return fmt.Errorf("validator %X: is unknown, its delegators have %d bond tokens",
+				bond.PubKey, tokens)
+		}
+	}
+	return nil
+}
+
+// no two validators share a pubkey
+func uniquePubKeys(s appState) error {
+	seen := make(map[string]bool)
+	for _, bond := range s.Bonds {
+		if seen[string(bond.PubKey)] {
+			return fmt.Errorf("validator %X: the pubkey is used by more than one validator",
+				bond.PubKey)
+		}
+		seen[string(bond.PubKey)] = true
+	}
+	return nil
+}
+
+// the bonds are in the order of their keys in the power index, by descending
+// voting power then by the encoded sender
+func sortedPower(s appState) error {
+	for i := 1; i < len(s.Bonds); i++ {
+		prev, bond := s.Bonds[i-1], s.Bonds[i]
+		if bytes.Compare(stake.ValidatorPowerKey(prev), stake.ValidatorPowerKey(bond)) >= 0 {
+			return fmt.Errorf("validator %X: with power %d is indexed after validator %X with power %d",
+				bond.PubKey, bond.VotingPower, prev.PubKey, prev.VotingPower)
+		}
+	}
+	return nil
+}
+
+// the validators left with no bond tokens have been removed, unless revoked
+func noEmptyBonds(s appState) error {
+	for _, bond := range s.Bonds {
+		if bond.BondedTokens == 0 && !bond.Revoked {
+			return fmt.Errorf("validator %X: has no bond tokens left but wasn't removed",
+				bond.PubKey)
+		}
+	}
+	return nil
+}
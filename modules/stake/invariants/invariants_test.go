@@ -0,0 +1,176 @@
+package invariants
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/tmlibs/log"
+
+	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/auth"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/stack"
+	"github.com/cosmos/cosmos-sdk/state"
+
+	"github.com/cosmos/gaia/modules/stake"
+)
+
+// a valid state, two validators, one of them with a delegator and some coins
+// unbonding
+func validState() appState {
+	val1, val2, delegator := auth.SigPerm([]byte("val1")), auth.SigPerm([]byte("val2")),
+		auth.SigPerm([]byte("delegator"))
+	hold1, hold2 := sdk.NewActor(stake.Name(), []byte("hold1")), sdk.NewActor(stake.Name(), []byte("hold2"))
+
+	s := appState{}
+	s.Params.AllowedBondDenom = "fermion"
+	s.Params.TotalSupply = 1000
+	s.Bonds = []*stake.ValidatorBond{
+		{Sender: val1, PubKey: []byte("pk1"), BondedTokens: 300, BondedCoins: 300,
+			HoldAccount: hold1, VotingPower: 300},
+		{Sender: val2, PubKey: []byte("pk2"), BondedTokens: 100, BondedCoins: 200,
+			HoldAccount: hold2, VotingPower: 200},
+	}
+	s.Delegations = []*stake.DelegatorBond{
+		{Delegator: val1, PubKey: []byte("pk1"), BondedTokens: 200},
+		{Delegator: delegator, PubKey: []byte("pk1"), BondedTokens: 100},
+		{Delegator: val2, PubKey: []byte("pk2"), BondedTokens: 100},
+	}
+	s.UnbondingQueue = []*stake.UnbondingQueueElem{
+		{Delegator: delegator, PubKey: []byte("pk1"), HoldAccount: hold1, Amount: 50},
+	}
+	s.Accounts = []account{
+		{hold1, coin.Coins{{"fermion", 350}}},
+		{hold2, coin.Coins{{"atom", 10}, {"fermion", 200}}},
+		{delegator, coin.Coins{{"fermion", 450}}},
+		{val1, coin.Coins{{"atom", 1000}}},
+	}
+	return s
+}
+
+func TestInvariants(t *testing.T) {
+	assert := assert.New(t)
+
+	s := validState()
+	for _, inv := range invariants {
+		assert.Nil(inv(s))
+	}
+
+	testCases := []struct {
+		name        string
+		change      func(s *appState)
+		errContains string
+	}{
+		{"coins minted", func(s *appState) {
+			s.Accounts[2].Coins[0].Amount++
+		}, "supply"},
+		{"supply not updated", func(s *appState) {
+			s.Provisions = &stake.Provisions{TotalSupply: 1100}
+		}, "supply"},
+		{"coins missing from a hold account", func(s *appState) {
+			s.Accounts[0].Coins[0].Amount -= 50
+			s.Accounts[2].Coins[0].Amount += 50
+		}, "hold account 686F6C6431: holds 300fermion, 350"},
+		{"unbonding forgotten", func(s *appState) {
+			s.UnbondingQueue = nil
+		}, "hold account 686F6C6431: holds 350fermion, 300"},
+		{"hold account missing", func(s *appState) {
+			s.Accounts = append(s.Accounts[1:2], account{s.Accounts[2].Actor, coin.Coins{{"fermion", 800}}})
+		}, "hold account 686F6C6431: holds 0fermion"},
+		{"tokens lost", func(s *appState) {
+			s.Delegations[1].BondedTokens--
+		}, "has 300 bond tokens, its delegators 299"},
+		{"delegation to an unknown validator", func(s *appState) {
+			s.Delegations = append(s.Delegations, &stake.DelegatorBond{PubKey: []byte("pk3"), BondedTokens: 1})
+		}, "is unknown"},
+		{"duplicate pubkey", func(s *appState) {
+			s.Bonds[1].PubKey = []byte("pk1")
+		}, "used by more than one validator"},
+		{"power out of order", func(s *appState) {
+			s.Bonds[0], s.Bonds[1] = s.Bonds[1], s.Bonds[0]
+		}, "with power 300 is indexed after"},
+		{"empty bond", func(s *appState) {
+			s.Bonds = append(s.Bonds, &stake.ValidatorBond{Sender: auth.SigPerm([]byte("val3")),
+				PubKey: []byte("pk3")})
+		}, "no bond tokens left"},
+	}
+	for _, tc := range testCases {
+		s := validState()
+		tc.change(&s)
+
+		var err error
+		for _, inv := range invariants {
+			if err = inv(s); err != nil {
+				break
+			}
+		}
+		if assert.NotNil(err, tc.name) {
+			assert.Contains(err.Error(), tc.errContains, tc.name)
+		}
+	}
+
+	// revoked validators are kept without tokens
+	s = validState()
+	s.Bonds = append(s.Bonds, &stake.ValidatorBond{Sender: auth.SigPerm([]byte("val3")),
+		PubKey: []byte("pk3"), Revoked: true})
+	assert.Nil(noEmptyBonds(s))
+}
+
+func TestSortedPowerEqualPower(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	// of equal power, the validators are indexed by their encoded sender, in
+	// which the address is length prefixed, not by the address alone
+	bonds := []*stake.ValidatorBond{
+		{Sender: auth.SigPerm([]byte("aa")), PubKey: []byte("pk1"), VotingPower: 100},
+		{Sender: auth.SigPerm([]byte("b")), PubKey: []byte("pk2"), VotingPower: 100},
+		{Sender: auth.SigPerm([]byte("ccc")), PubKey: []byte("pk3"), VotingPower: 100},
+	}
+	value, err := json.Marshal(map[string]interface{}{"bonds": bonds})
+	require.Nil(err)
+	store := state.NewMemKVStore()
+	_, err = stake.NewHandler().InitState(log.NewNopLogger(), store, stake.Name(), "genesis", string(value), nil)
+	require.Nil(err)
+
+	s := appState{}
+	s.Bonds = stake.LoadBonds(store)
+	require.Equal(3, len(s.Bonds))
+	assert.Nil(sortedPower(s))
+
+	s.Bonds[0], s.Bonds[1] = s.Bonds[1], s.Bonds[0]
+	assert.NotNil(sortedPower(s))
+}
+
+func TestCheck(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	stakeStore := stack.PrefixedStore(stake.Name(), store)
+	coinStore := stack.PrefixedStore(coin.NameCoin, store)
+
+	// a genesis validator bonding 100 of the supply of 1000
+	pubKey := crypto.GenPrivKeyEd25519().PubKey()
+	value := fmt.Sprintf(`{"params": {"total_supply": 1000}, "validators": [{"address": "0102",`+
+		` "pub_key": {"type": "ed25519", "data": "%X"}, "amount": {"denom": "fermion", "amount": 100}}]}`,
+		pubKey.Bytes())
+	_, err := stake.NewHandler().InitState(log.NewNopLogger(), stakeStore, stake.Name(), "genesis", value, nil)
+	require.Nil(err)
+	require.Nil(stake.ProcessGenesisCoins(stakeStore, coinStore))
+	_, err = coin.ChangeCoins(coinStore, auth.SigPerm([]byte{1, 2}), coin.Coins{{"fermion", 900}})
+	require.Nil(err)
+	assert.Nil(Check(store))
+
+	// coins appearing in a hold account break the invariants
+	hold := stake.LoadBonds(stakeStore)[0].HoldAccount
+	_, err = coin.ChangeCoins(coinStore, hold, coin.Coins{{"fermion", 1}})
+	require.Nil(err)
+	err = Check(store)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "supply")
+	}
+}
@@ -0,0 +1,125 @@
+package stake
+
+import (
+	"math/big"
+
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+// Provisions - the state of the inflation of the bond denomination. New coins
+// are minted every block and distributed to the bonded validators, raising the
+// exchange rate of their bond tokens.
+type Provisions struct {
+	TotalSupply uint64   `json:"total_supply"` // supply of the bond denom including provisions
+	BondedRatio Fraction `json:"bonded_ratio"` // fraction of the supply bonded to validators
+	Inflation   Fraction `json:"inflation"`    // current annual inflation rate
+}
+
+func initialProvisions(params Params) Provisions {
+	return Provisions{
+		TotalSupply: params.TotalSupply,
+		BondedRatio: NewFraction(0, 1),
+		Inflation:   NewFraction(0, 1),
+	}
+}
+
+// inflation falls linearly from the maximum rate when nothing is bonded, to the
+// minimum rate once the bonded ratio has reached the goal
+func (p Params) inflation(bondedRatio *big.Rat) *big.Rat {
+	min, max, goal := p.InflationRateMin.Rat(), p.InflationRateMax.Rat(), p.GoalBonded.Rat()
+	if goal.Sign() <= 0 || bondedRatio.Cmp(goal) >= 0 {
+		return min
+	}
+
+	progress := new(big.Rat).Quo(bondedRatio, goal)
+	decrease := new(big.Rat).Sub(max, min)
+	decrease.Mul(decrease, progress)
+	return decrease.Sub(max, decrease)
+}
+
+// ProcessProvisions - mint the provisions for this block and distribute them
+// to the bonded validators pro-rata to their bonded coins. The commission of
+// each validator is paid to its sender, the rest is bonded. The coins are
+// minted directly within coinStore, the store of the coin module.
+func ProcessProvisions(store, coinStore state.SimpleDB) error {
+	return processProvisions(store, storeChangeCoinsFn(coinStore))
+}
+
+// separated for testing
+func processProvisions(store state.SimpleDB, mintFn changeCoinsFn) error {
+	params := loadParams(store)
+	provisions := loadProvisions(store)
+
+	// there is no inflation until the supply is set in the genesis
+	if provisions.TotalSupply == 0 {
+		return nil
+	}
+
+	// only the validators in the validator set are bonded
+	bonds := LoadBonds(store)
+	var bonded uint64
+	for _, vb := range bonds {
+		if vb.VotingPower > 0 {
+			bonded += vb.BondedCoins
+		}
+	}
+
+	totalSupply := new(big.Int).SetUint64(provisions.TotalSupply)
+	bondedRatio := new(big.Rat).SetFrac(new(big.Int).SetUint64(bonded), totalSupply)
+	inflation := params.inflation(bondedRatio)
+
+	// the annual provisions are spread evenly over the blocks of the year
+	var blockProvisions uint64
+	if params.BlocksPerYear > 0 {
+		perBlock := new(big.Rat).Mul(inflation, new(big.Rat).SetInt(totalSupply))
+		perBlock.Quo(perBlock, new(big.Rat).SetInt(new(big.Int).SetUint64(params.BlocksPerYear)))
+		blockProvisions = new(big.Int).Quo(perBlock.Num(), perBlock.Denom()).Uint64()
+	}
+
+	// any remainder from rounding down the rewards is never minted
+	var minted uint64
+	if blockProvisions > 0 && bonded > 0 {
+		for _, vb := range bonds {
+			if vb.VotingPower == 0 {
+				continue
+			}
+			reward := mulDiv(blockProvisions, vb.BondedCoins, bonded, false)
+			if reward == 0 {
+				continue
+			}
+
+			// the commission is paid to the sender before the delegators' share
+			rate := vb.Commission.Rate
+			var commission uint64
+			if rate.Num > 0 && rate.Denom > 0 {
+				commission = mulDiv(reward, uint64(rate.Num), uint64(rate.Denom), false)
+			}
+			if commission > 0 {
+				commissionCoin := coin.Coin{params.AllowedBondDenom, int64(commission)}
+				res := mintFn(vb.Sender, coin.Coins{commissionCoin})
+				if res.IsErr() {
+					return res
+				}
+			}
+
+			delegatorsReward := reward - commission
+			if delegatorsReward > 0 {
+				rewardCoin := coin.Coin{params.AllowedBondDenom, int64(delegatorsReward)}
+				res := mintFn(vb.HoldAccount, coin.Coins{rewardCoin})
+				if res.IsErr() {
+					return res
+				}
+				vb.BondedCoins += delegatorsReward
+				saveValidatorBond(store, vb)
+			}
+			minted += reward
+		}
+	}
+
+	provisions.TotalSupply += minted
+	provisions.BondedRatio = fractionFromRat(bondedRatio)
+	provisions.Inflation = fractionFromRat(inflation)
+	saveProvisions(store, provisions)
+	return nil
+}
@@ -0,0 +1,123 @@
+package stake
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+func dummyChangeCoinsFn(store map[string]int64) changeCoinsFn {
+	return func(addr sdk.Actor, coins coin.Coins) abci.Result {
+		store[string(addr.Address)] += int64(coins[0].Amount)
+		return abci.OK
+	}
+}
+
+func TestInflation(t *testing.T) {
+	assert := assert.New(t)
+	params := defaultParams()
+	params.InflationRateMin = NewFraction(5, 100)
+	params.InflationRateMax = NewFraction(15, 100)
+	params.GoalBonded = NewFraction(1, 2)
+
+	testCases := []struct {
+		bondedRatio, expected *big.Rat
+	}{
+		{big.NewRat(0, 1), big.NewRat(15, 100)},
+		{big.NewRat(1, 4), big.NewRat(10, 100)},
+		{big.NewRat(1, 2), big.NewRat(5, 100)},
+		{big.NewRat(9, 10), big.NewRat(5, 100)},
+	}
+
+	for _, tc := range testCases {
+		got := params.inflation(tc.bondedRatio)
+		assert.Equal(0, tc.expected.Cmp(got), "ratio %v, expected %v, got %v",
+			tc.bondedRatio, tc.expected, got)
+	}
+}
+
+func TestProcessProvisions(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(3)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{100, 300, 600}))
+	bonds[2].VotingPower = 0 // not in the validator set, so not bonded
+	saveBonds(store, bonds)
+
+	// no provisions until the total supply is set
+	require.Nil(processProvisions(store, dummyChangeCoinsFn(accStore)))
+	assert.Equal(uint64(100), loadValidatorBond(store, actors[0]).BondedCoins)
+
+	params := defaultParams()
+	params.TotalSupply = 1600
+	params.BlocksPerYear = 2
+	params.InflationRateMin = NewFraction(10, 100)
+	params.InflationRateMax = NewFraction(10, 100)
+	saveParams(store, params)
+
+	// 10% of 1600 over 2 blocks is 80, split 1:3 between the bonded validators
+	require.Nil(processProvisions(store, dummyChangeCoinsFn(accStore)))
+	bonds = ValidatorBonds{}
+	for _, actor := range actors {
+		bonds = append(bonds, loadValidatorBond(store, actor))
+	}
+	assert.Equal(uint64(120), bonds[0].BondedCoins)
+	assert.Equal(uint64(360), bonds[1].BondedCoins)
+	assert.Equal(uint64(600), bonds[2].BondedCoins)
+	assert.Equal(int64(20), accStore[string(bonds[0].HoldAccount.Address)])
+	assert.Equal(int64(60), accStore[string(bonds[1].HoldAccount.Address)])
+
+	// the rewards raise the exchange rate, the bond tokens are unchanged
+	assert.Equal(uint64(100), bonds[0].BondedTokens)
+	assert.Equal(0, bonds[0].ExchangeRate().Cmp(big.NewRat(6, 5)))
+
+	provisions := loadProvisions(store)
+	assert.Equal(uint64(1680), provisions.TotalSupply)
+	assert.Equal(0, provisions.BondedRatio.Rat().Cmp(big.NewRat(1, 4)))
+	assert.Equal(0, provisions.Inflation.Rat().Cmp(big.NewRat(1, 10)))
+}
+
+func TestProcessProvisionsCommission(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(2)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{100, 300}))
+	bonds[0].Commission = NewCommission(NewFraction(1, 4), NewFraction(1, 2), NewFraction(1, 10))
+	saveBonds(store, bonds)
+
+	params := defaultParams()
+	params.TotalSupply = 1600
+	params.BlocksPerYear = 2
+	params.InflationRateMin = NewFraction(10, 100)
+	params.InflationRateMax = NewFraction(10, 100)
+	saveParams(store, params)
+
+	// the first validator keeps a quarter of its reward of 20 as commission
+	require.Nil(processProvisions(store, dummyChangeCoinsFn(accStore)))
+	bonds = ValidatorBonds{loadValidatorBond(store, actors[0]), loadValidatorBond(store, actors[1])}
+	assert.Equal(uint64(115), bonds[0].BondedCoins)
+	assert.Equal(uint64(360), bonds[1].BondedCoins)
+	assert.Equal(int64(5), accStore[string(bonds[0].Sender.Address)])
+	assert.Equal(int64(15), accStore[string(bonds[0].HoldAccount.Address)])
+	assert.Equal(int64(0), accStore[string(bonds[1].Sender.Address)])
+
+	// the commission counts towards the supply
+	assert.Equal(uint64(1680), loadProvisions(store).TotalSupply)
+
+	// the provisions track the supply from now on, the param can't change it
+	assert.Equal(errTotalSupplyTracked, SetParam(store, "total_supply", "2000"))
+	assert.Equal(uint64(1600), loadParams(store).TotalSupply)
+	assert.Nil(SetParam(store, "blocks_per_year", "3"))
+}
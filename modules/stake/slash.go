@@ -0,0 +1,176 @@
+package stake
+
+import (
+	"bytes"
+
+	abci "github.com/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+// Slash - burn a fraction of the coins bonded to the validator with the pubkey,
+// including the coins which are still unbonding or being redelegated from it.
+// The coins are burned directly from the hold accounts within coinStore, the
+// store of the coin module.
+func Slash(store, coinStore state.SimpleDB, pubKey []byte, fraction Fraction) error {
+	return slash(store, storeChangeCoinsFn(coinStore), pubKey, fraction)
+}
+
+// separated for testing
+func slash(store state.SimpleDB, burnFn changeCoinsFn,
+	pubKey []byte, fraction Fraction) error {
+
+	if fraction.Denom <= 0 || fraction.Num < 0 || fraction.Num > fraction.Denom {
+		return errBadSlashFraction
+	}
+	num, denom := uint64(fraction.Num), uint64(fraction.Denom)
+	bondDenom := loadParams(store).AllowedBondDenom
+
+	var burned uint64
+
+	// the validator may already have been removed if all its tokens are unbonding
+	bond := loadValidatorBondByPubKey(store, pubKey)
+	if bond != nil {
+		slashed := mulDiv(bond.BondedCoins, num, denom, false)
+		if slashed > 0 {
+			res := burnFn(bond.HoldAccount, coin.Coins{{bondDenom, -int64(slashed)}})
+			if res.IsErr() {
+				return res
+			}
+			bond.BondedCoins -= slashed
+			burned += slashed
+		}
+		saveValidatorBond(store, bond)
+	}
+
+	// coins unbonding from the validator are slashed as well
+	for _, elem := range loadFullUnbondingQueue(store) {
+		if !bytes.Equal(elem.PubKey, pubKey) {
+			continue
+		}
+		slashed := mulDiv(elem.Amount, num, denom, false)
+		if slashed == 0 {
+			continue
+		}
+		res := burnFn(elem.HoldAccount, coin.Coins{{bondDenom, -int64(slashed)}})
+		if res.IsErr() {
+			return res
+		}
+		elem.Amount -= slashed
+		burned += slashed
+		saveUnbondingQueueElem(store, elem)
+	}
+
+	// coins redelegated from the validator are slashed from the bond of the
+	// delegator to the validator they were redelegated to
+	for _, elem := range loadFullRedelegationQueue(store) {
+		if !bytes.Equal(elem.PubKeyFrom, pubKey) {
+			continue
+		}
+		slashed, res := slashRedelegation(store, burnFn, bondDenom, elem, num, denom)
+		if res.IsErr() {
+			return res
+		}
+		burned += slashed
+	}
+
+	burnSupply(store, burned)
+	return nil
+}
+
+// BurnSupply - remove the coins of the bond denom burned by another module from
+// the supply, as the coins burned by slashing are
+func BurnSupply(store state.SimpleDB, coins coin.Coins) {
+	bondDenom := loadParams(store).AllowedBondDenom
+	var burned uint64
+	for _, c := range coins {
+		if c.Denom == bondDenom && c.Amount > 0 {
+			burned += uint64(c.Amount)
+		}
+	}
+	burnSupply(store, burned)
+}
+
+// the burned coins are removed from the supply, if it is being tracked
+func burnSupply(store state.SimpleDB, burned uint64) {
+	provisions := loadProvisions(store)
+	if provisions.TotalSupply >= burned {
+		provisions.TotalSupply -= burned
+		saveProvisions(store, provisions)
+	}
+}
+
+// slash a fraction of redelegated coins, the delegator can at most lose the
+// tokens it still has bonded to the validator the coins were redelegated to
+func slashRedelegation(store state.SimpleDB, burnFn changeCoinsFn, bondDenom string,
+	elem *RedelegationQueueElem, num, denom uint64) (uint64, abci.Result) {
+
+	slashed := mulDiv(elem.Amount, num, denom, false)
+	bond := loadValidatorBondByPubKey(store, elem.PubKeyTo)
+	delegatorBond := loadDelegatorBond(store, elem.Delegator, elem.PubKeyTo)
+	if slashed == 0 || bond == nil || delegatorBond == nil {
+		return 0, abci.OK
+	}
+
+	tokens, err := bond.TokensFromCoins(slashed, true)
+	if err != nil || tokens > delegatorBond.BondedTokens {
+		tokens = delegatorBond.BondedTokens
+		slashed = bond.CoinsFromTokens(tokens)
+	}
+
+	res := burnFn(bond.HoldAccount, coin.Coins{{bondDenom, -int64(slashed)}})
+	if res.IsErr() {
+		return 0, res
+	}
+
+	delegatorBond.BondedTokens -= tokens
+	bond.BondedTokens -= tokens
+	bond.BondedCoins -= slashed
+	if delegatorBond.BondedTokens == 0 {
+		removeDelegatorBond(store, elem.Delegator, elem.PubKeyTo)
+	} else {
+		saveDelegatorBond(store, delegatorBond)
+	}
+	saveValidatorBond(store, bond)
+
+	// later slashes apply to the reduced amount, as for bonded coins
+	elem.Amount -= slashed
+	saveRedelegationQueueElem(store, elem)
+	return slashed, abci.OK
+}
+
+// ProcessByzantineValidators - slash and revoke the validators tendermint has
+// reported evidence of double signing for. Revoked validators are permanently
+// removed from the validator set, their delegators can still unbond.
+func ProcessByzantineValidators(store, coinStore state.SimpleDB,
+	evidence []*abci.Evidence) error {
+
+	return processByzantineValidators(store, storeChangeCoinsFn(coinStore), evidence)
+}
+
+// separated for testing
+func processByzantineValidators(store state.SimpleDB, burnFn changeCoinsFn,
+	evidence []*abci.Evidence) error {
+
+	fraction := loadParams(store).SlashFractionDoubleSign
+	for _, ev := range evidence {
+		// a validator is punished once, repeated or late evidence is ignored
+		bond := loadValidatorBondByPubKey(store, ev.PubKey)
+		if bond != nil && bond.Revoked {
+			continue
+		}
+
+		err := slash(store, burnFn, ev.PubKey, fraction)
+		if err != nil {
+			return err
+		}
+
+		bond = loadValidatorBondByPubKey(store, ev.PubKey)
+		if bond != nil {
+			bond.Revoked = true
+			saveValidatorBond(store, bond)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package stake
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/modules/coin"
+	"github.com/cosmos/cosmos-sdk/state"
+)
+
+func TestSlash(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(2)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{1000, 500}))
+	saveBonds(store, bonds)
+	pubKey, holder := bonds[0].PubKey, bonds[0].HoldAccount
+	accStore[string(holder.Address)] = 1000
+
+	params := defaultParams()
+	params.TotalSupply = 10000
+	saveParams(store, params)
+
+	// some coins are already unbonding from the validator
+	pushUnbondingQueue(store, UnbondingQueueElem{
+		Delegator:     actors[1],
+		PubKey:        pubKey,
+		HoldAccount:   holder,
+		Amount:        200,
+		HeightRelease: 10,
+	})
+	accStore[string(holder.Address)] += 200
+
+	err := slash(store, dummyChangeCoinsFn(accStore), pubKey, NewFraction(1, 10))
+	require.Nil(err)
+
+	// the bonded coins and the unbonding coins are slashed alike
+	bonds = LoadBonds(store)
+	assert.Equal(uint64(900), bonds[0].BondedCoins)
+	assert.Equal(uint64(1000), bonds[0].BondedTokens)
+	assert.Equal(uint64(500), bonds[1].BondedCoins)
+	queue := loadFullUnbondingQueue(store)
+	require.Equal(1, len(queue))
+	assert.Equal(uint64(180), queue[0].Amount)
+	assert.Equal(int64(1080), accStore[string(holder.Address)])
+	assert.Equal(uint64(9880), loadProvisions(store).TotalSupply)
+
+	// the slashed unbonding is released at the reduced amount
+	err = processUnbondingQueue(store, dummyTransferFn(accStore), 10)
+	require.Nil(err)
+	assert.Equal(int64(180), accStore[string(actors[1].Address)])
+
+	// bad fractions are rejected
+	err = slash(store, dummyChangeCoinsFn(accStore), pubKey, NewFraction(3, 2))
+	assert.Equal(errBadSlashFraction, err)
+	err = slash(store, dummyChangeCoinsFn(accStore), pubKey, Fraction{1, 0})
+	assert.Equal(errBadSlashFraction, err)
+}
+
+func TestBurnSupply(t *testing.T) {
+	assert := assert.New(t)
+
+	store := state.NewMemKVStore()
+	params := defaultParams()
+	params.TotalSupply = 1000
+	saveParams(store, params)
+	saveProvisions(store, initialProvisions(params))
+
+	// only the coins of the bond denom are part of the supply
+	BurnSupply(store, coin.Coins{{"atom", 50}, {"fermion", 100}})
+	assert.Equal(uint64(900), loadProvisions(store).TotalSupply)
+
+	// the supply never goes below zero
+	BurnSupply(store, coin.Coins{{"fermion", 1000}})
+	assert.Equal(uint64(900), loadProvisions(store).TotalSupply)
+}
+
+func TestProcessByzantineValidators(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(3)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{1000, 600, 300}))
+	saveBonds(store, bonds)
+	byzantine := bonds[1]
+
+	evidence := []*abci.Evidence{{PubKey: byzantine.PubKey, Height: 5}}
+	err := processByzantineValidators(store, dummyChangeCoinsFn(accStore), evidence)
+	require.Nil(err)
+
+	// 5% of the bonded coins is burned and the validator is revoked
+	bonds = LoadBonds(store)
+	_, bond := bonds.GetByPubKey(byzantine.PubKey)
+	require.NotNil(bond)
+	assert.True(bond.Revoked)
+	assert.Equal(uint64(570), bond.BondedCoins)
+	assert.Equal(int64(-30), accStore[string(bond.HoldAccount.Address)])
+
+	// the revoked validator loses its voting power and leaves the validator set
+	startVals := bonds.GetValidators(store)
+	require.True(bonds.UpdateVotingPower(store))
+	newVals := bonds.GetValidators(store)
+	diff := ValidatorsDiff(startVals, newVals, store)
+	require.Equal(1, len(diff))
+	assert.Equal(byzantine.PubKey, diff[0].PubKey)
+	assert.Equal(uint64(0), diff[0].Power)
+
+	// the revoked validator can no longer be bonded or delegated to
+	sender := byzantine.Sender
+	txBond := newTxBond(10)
+	txBond.PubKey = byzantine.PubKey
+	assert.Equal(errValidatorRevoked, checkTxBond(txBond, sender, "testChain", store))
+	txDelegate := TxDelegate{Amount: coin.Coin{"fermion", 10}, PubKey: byzantine.PubKey}
+	assert.Equal(errValidatorRevoked, checkTxDelegate(txDelegate, store))
+}
+
+func TestProcessByzantineValidatorsRepeatedEvidence(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	accStore := map[string]int64{}
+	actors := newActors(3)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{1000, 600, 300}))
+	saveBonds(store, bonds)
+	byzantine := bonds[1]
+
+	evidence := []*abci.Evidence{{PubKey: byzantine.PubKey, Height: 5}}
+	err := processByzantineValidators(store, dummyChangeCoinsFn(accStore), evidence)
+	require.Nil(err)
+	supply := loadProvisions(store).TotalSupply
+
+	// the same evidence again, or late evidence of the same fault, burns nothing
+	for _, height := range []int64{5, 8} {
+		evidence = []*abci.Evidence{{PubKey: byzantine.PubKey, Height: height}}
+		err = processByzantineValidators(store, dummyChangeCoinsFn(accStore), evidence)
+		require.Nil(err)
+
+		_, bond := LoadBonds(store).GetByPubKey(byzantine.PubKey)
+		require.NotNil(bond)
+		assert.True(bond.Revoked)
+		assert.Equal(uint64(570), bond.BondedCoins)
+		assert.Equal(int64(-30), accStore[string(bond.HoldAccount.Address)])
+		assert.Equal(supply, loadProvisions(store).TotalSupply)
+	}
+}
@@ -1,6 +1,9 @@
 package stake
 
 import (
+	"bytes"
+	"encoding/binary"
+
 	abci "github.com/tendermint/abci/types"
 
 	"github.com/tendermint/go-wire"
@@ -29,33 +32,682 @@ func defaultTransferFn(ctx sdk.Context, store state.SimpleDB, dispatch sdk.Deliv
 	}
 }
 
-// BondKey - state key for the bond bytes
+// transfer coins by writing directly to the coin store, for use outside of a
+// tx (from the tick) where there is no dispatcher to run a SendTx through
+func storeTransferFn(coinStore state.SimpleDB) transferFn {
+	return func(sender, receiver sdk.Actor, coins coin.Coins) (res abci.Result) {
+		_, err := coin.ChangeCoins(coinStore, sender, coins.Negative())
+		if err != nil {
+			return abci.ErrInsufficientFunds.AppendLog(err.Error())
+		}
+
+		_, err = coin.ChangeCoins(coinStore, receiver, coins)
+		if err != nil {
+			return abci.ErrInternalError.AppendLog(err.Error())
+		}
+
+		return abci.OK
+	}
+}
+
+// mint coins to, or with negative coins burn coins from, an account
+type changeCoinsFn func(addr sdk.Actor, coins coin.Coins) abci.Result
+
+// change coins by writing directly to the coin store, used from the tick
+func storeChangeCoinsFn(coinStore state.SimpleDB) changeCoinsFn {
+	return func(addr sdk.Actor, coins coin.Coins) (res abci.Result) {
+		_, err := coin.ChangeCoins(coinStore, addr, coins)
+		if err != nil {
+			return abci.ErrInternalError.AppendLog(err.Error())
+		}
+		return abci.OK
+	}
+}
+
+// nolint - state keys for the stake store
 var (
-	BondKey  = []byte{0x00}
-	ParamKey = []byte{0x01}
+	BondKey                 = []byte{0x00} // key for the validator bonds in the legacy layout
+	ParamKey                = []byte{0x01} // key for the global staking params
+	DelegatorBondKeyPrefix  = []byte{0x02} // prefix for each key to a delegator bond
+	UnbondingQueueKeyPrefix = []byte{0x03} // prefix for each key to unbonding coins
+	ProvisionsKey           = []byte{0x04} // key for the state of the block provisions
+	SigningInfoKeyPrefix    = []byte{0x05} // prefix for each key to a validator signing info
+	SignersKey              = []byte{0x06} // key for the validator set signing the last block
+	ValidatorKeyPrefix      = []byte{0x07} // prefix for each key to a validator bond
+	ValidatorPubKeyPrefix   = []byte{0x08} // prefix for the index of validator bonds by pubkey
+	ValidatorPowerPrefix    = []byte{0x09} // prefix for the index of validator bonds by power
+	ValidatorSetKey         = []byte{0x0A} // key for the current validator set
+	RedelegationKeyPrefix   = []byte{0x0B} // prefix for each key to redelegated coins
+	GenesisCoinsKey         = []byte{0x0C} // key for the coins bonded by the genesis validators
+	NextValidatorSetKey     = []byte{0x0D} // key for the validator set of the next epoch
+	HoldAccountsMigratedKey = []byte{0x0E} // key set once the hold accounts have been migrated
+	ValidatorListKey        = []byte{0x0F} // key for the senders of all the validator bonds
 )
 
-// LoadBonds - loads the validator bond set
+// ValidatorKey - state key for the validator bond of a sender
+func ValidatorKey(sender sdk.Actor) []byte {
+	return append(ValidatorKeyPrefix, wire.BinaryBytes(&sender)...)
+}
+
+// ValidatorPubKeyKey - state key for the index of a validator bond by pubkey,
+// it holds the ValidatorKey of the bond
+func ValidatorPubKeyKey(pubKey []byte) []byte {
+	return append(ValidatorPubKeyPrefix, pubKey...)
+}
+
+// ValidatorPowerKey - state key for the index of a validator bond by voting
+// power, the index is ordered by descending voting power, then by the encoded
+// sender
+func ValidatorPowerKey(bond *ValidatorBond) []byte {
+	key := make([]byte, len(ValidatorPowerPrefix)+8)
+	copy(key, ValidatorPowerPrefix)
+	binary.BigEndian.PutUint64(key[len(ValidatorPowerPrefix):], ^bond.VotingPower)
+	return append(key, wire.BinaryBytes(&bond.Sender)...)
+}
+
+// the end of the range of the keys starting with the prefix
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// SigningInfoKey - state key for the signing info of a validator
+func SigningInfoKey(pubKey []byte) []byte {
+	return append(SigningInfoKeyPrefix, pubKey...)
+}
+
+// DelegatorBondKey - state key for the bond of a delegator to a validator
+func DelegatorBondKey(delegator sdk.Actor, pubKey []byte) []byte {
+	return append(delegatorBondsKey(delegator), pubKey...)
+}
+
+// all the bonds of one delegator are stored under the same prefix
+func delegatorBondsKey(delegator sdk.Actor) []byte {
+	return append(DelegatorBondKeyPrefix, wire.BinaryBytes(&delegator)...)
+}
+
+// LoadBonds - loads all the validator bonds, ordered by the power index
 // TODO ultimately this function should be made unexported... being used right now
 // for patchwork of tick functionality therefor much easier if exported until
 // the new SDK is created
 func LoadBonds(store state.SimpleDB) (validatorBonds ValidatorBonds) {
+	end := []byte{ValidatorPowerPrefix[0] + 1}
+	for _, model := range store.List(ValidatorPowerPrefix, end, 0) {
+		validatorBonds = append(validatorBonds, loadValidatorBondAt(store, model.Value))
+	}
+	return
+}
+
+func saveBonds(store state.SimpleDB, validatorBonds ValidatorBonds) {
+	for _, bond := range validatorBonds {
+		saveValidatorBond(store, bond)
+	}
+}
+
+// load the validator bond of a sender, or nil if it has none
+func loadValidatorBond(store state.SimpleDB, sender sdk.Actor) *ValidatorBond {
+	return loadValidatorBondAt(store, ValidatorKey(sender))
+}
+
+// load the validator bond with a pubkey, or nil if there is none
+func loadValidatorBondByPubKey(store state.SimpleDB, pubKey []byte) *ValidatorBond {
+	key := store.Get(ValidatorPubKeyKey(pubKey))
+	if key == nil {
+		return nil
+	}
+	return loadValidatorBondAt(store, key)
+}
+
+func loadValidatorBondAt(store state.SimpleDB, key []byte) *ValidatorBond {
+	b := store.Get(key)
+	if b == nil {
+		return nil
+	}
+
+	bond := new(ValidatorBond)
+	err := wire.ReadBinaryBytes(b, bond)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+
+	return bond
+}
+
+// save a validator bond along with its entries in the indexes
+func saveValidatorBond(store state.SimpleDB, bond *ValidatorBond) {
+	key := ValidatorKey(bond.Sender)
+	if old := loadValidatorBondAt(store, key); old != nil {
+		store.Remove(ValidatorPowerKey(old))
+	} else {
+		addValidatorListSender(store, bond.Sender)
+	}
+
+	store.Set(key, wire.BinaryBytes(*bond))
+	store.Set(ValidatorPubKeyKey(bond.PubKey), key)
+	store.Set(ValidatorPowerKey(bond), key)
+}
+
+func removeValidatorBond(store state.SimpleDB, bond *ValidatorBond) {
+	key := ValidatorKey(bond.Sender)
+	if old := loadValidatorBondAt(store, key); old != nil {
+		store.Remove(ValidatorPowerKey(old))
+	}
+
+	store.Remove(key)
+	store.Remove(ValidatorPubKeyKey(bond.PubKey))
+	removeValidatorListSender(store, bond.Sender)
+}
+
+// the senders of all the validator bonds, ordered by their encoding so the
+// list doesn't depend on the order the bonds were declared in. It only changes
+// when a bond is added or removed, and lets clients list the bonds with
+// queries by key.
+func loadValidatorList(store state.SimpleDB) (senders []sdk.Actor) {
+	b := store.Get(ValidatorListKey)
+	if b == nil {
+		return
+	}
+	err := wire.ReadBinaryBytes(b, &senders)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+	return
+}
+
+func saveValidatorList(store state.SimpleDB, senders []sdk.Actor) {
+	if len(senders) == 0 {
+		store.Remove(ValidatorListKey)
+		return
+	}
+	store.Set(ValidatorListKey, wire.BinaryBytes(senders))
+}
+
+func addValidatorListSender(store state.SimpleDB, sender sdk.Actor) {
+	senders := loadValidatorList(store)
+	key := wire.BinaryBytes(&sender)
+	i := 0
+	for ; i < len(senders); i++ {
+		if bytes.Compare(key, wire.BinaryBytes(&senders[i])) < 0 {
+			break
+		}
+	}
+	senders = append(senders, sdk.Actor{})
+	copy(senders[i+1:], senders[i:])
+	senders[i] = sender
+	saveValidatorList(store, senders)
+}
+
+func removeValidatorListSender(store state.SimpleDB, sender sdk.Actor) {
+	senders := loadValidatorList(store)
+	for i := range senders {
+		if senders[i].Equals(sender) {
+			saveValidatorList(store, append(senders[:i], senders[i+1:]...))
+			return
+		}
+	}
+}
+
+// legacyValidatorBond - the layout of the validator bonds stored as a single
+// list under BondKey, frozen as go-wire has no optional fields
+type legacyValidatorBond struct {
+	Sender       sdk.Actor
+	PubKey       []byte
+	BondedTokens uint64
+	HoldAccount  sdk.Actor
+	VotingPower  uint64
+}
+
+// legacyParams - the layout of the params stored along the legacy bonds
+type legacyParams struct {
+	MaxVals          int
+	AllowedBondDenom string
+	GasBond          uint64
+	GasUnbond        uint64
+}
+
+// MigrateBonds - move the validator bonds from the single list stored under
+// BondKey to their own keys, with the self bond of each sender, and the params
+// of the same chains to the current layout. Does nothing once the bonds have
+// been migrated.
+func MigrateBonds(store state.SimpleDB) {
 	b := store.Get(BondKey)
 	if b == nil {
 		return
 	}
-	err := wire.ReadBinaryBytes(b, &validatorBonds)
 
+	if pb := store.Get(ParamKey); pb != nil {
+		var legacy legacyParams
+		err := wire.ReadBinaryBytes(pb, &legacy)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		params := defaultParams()
+		params.MaxVals = legacy.MaxVals
+		params.AllowedBondDenom = legacy.AllowedBondDenom
+		params.GasBond = legacy.GasBond
+		params.GasUnbond = legacy.GasUnbond
+		saveParams(store, params)
+	}
+
+	var legacyBonds []*legacyValidatorBond
+	err := wire.ReadBinaryBytes(b, &legacyBonds)
 	if err != nil {
 		panic(err) // This error should never occure big problem if does
 	}
 
+	// the legacy bond tokens were worth a coin each, all bonded by the sender
+	validatorBonds := make(ValidatorBonds, len(legacyBonds))
+	for i, legacy := range legacyBonds {
+		bond := NewValidatorBond(legacy.Sender, legacy.HoldAccount, legacy.PubKey)
+		bond.BondedTokens = legacy.BondedTokens
+		bond.BondedCoins = legacy.BondedTokens
+		bond.VotingPower = legacy.VotingPower
+		validatorBonds[i] = bond
+
+		delegatorBond := NewDelegatorBond(legacy.Sender, legacy.PubKey)
+		delegatorBond.BondedTokens = legacy.BondedTokens
+		saveDelegatorBond(store, delegatorBond)
+	}
+
+	// the list was kept sorted by voting power
+	saveBonds(store, validatorBonds)
+	saveValidatorSet(store, validatorBonds.GetValidators(store))
+	store.Remove(BondKey)
+}
+
+// MigrateHoldAccounts - move the coins of the validators out of the hold
+// accounts of older chains, whose address was the one of the sender with the
+// first byte zeroed, into the hold accounts derived by getHoldAccount. The old
+// accounts may be shared by several validators, only the coins bonded to each
+// validator and unbonding from it are moved. The coins unbonding from removed
+// validators, whose sender is no longer known, are moved to accounts derived
+// from their pubkey. The coins are moved directly within coinStore, the store
+// of the coin module. Once all the coins have been moved
+// HoldAccountsMigratedKey is set, and it does nothing afterwards.
+func MigrateHoldAccounts(store, coinStore state.SimpleDB) error {
+	return migrateHoldAccounts(store, storeTransferFn(coinStore))
+}
+
+// separated for testing
+func migrateHoldAccounts(store state.SimpleDB, transferFn transferFn) error {
+	if store.Get(HoldAccountsMigratedKey) != nil {
+		return nil
+	}
+
+	queue := loadFullUnbondingQueue(store)
+	for _, bond := range LoadBonds(store) {
+		holder := getHoldAccount(bond.Sender)
+		if bond.HoldAccount.Equals(holder) {
+			continue
+		}
+
+		amount := bond.BondedCoins
+		var unbonding []*UnbondingQueueElem
+		for _, elem := range queue {
+			if elem.HoldAccount.Equals(bond.HoldAccount) && bytes.Equal(elem.PubKey, bond.PubKey) {
+				amount += elem.Amount
+				unbonding = append(unbonding, elem)
+			}
+		}
+		if amount > 0 {
+			coins := coin.Coins{{loadParams(store).AllowedBondDenom, int64(amount)}}
+			res := transferFn(bond.HoldAccount, holder, coins)
+			if res.IsErr() {
+				return res
+			}
+		}
+
+		for _, elem := range unbonding {
+			elem.HoldAccount = holder
+			saveUnbondingQueueElem(store, elem)
+		}
+		bond.HoldAccount = holder
+		saveValidatorBond(store, bond)
+	}
+
+	// the coins unbonding from removed validators
+	for _, elem := range queue {
+		if loadValidatorBondByPubKey(store, elem.PubKey) != nil {
+			continue
+		}
+		holder := getRemovedHoldAccount(elem.PubKey)
+		if elem.HoldAccount.Equals(holder) {
+			continue
+		}
+		coins := coin.Coins{{loadParams(store).AllowedBondDenom, int64(elem.Amount)}}
+		res := transferFn(elem.HoldAccount, holder, coins)
+		if res.IsErr() {
+			return res
+		}
+		elem.HoldAccount = holder
+		saveUnbondingQueueElem(store, elem)
+	}
+	store.Set(HoldAccountsMigratedKey, []byte{0x01})
+	return nil
+}
+
+// the hold account of the coins unbonding from a validator removed before the
+// hold accounts were migrated
+func getRemovedHoldAccount(pubKey []byte) sdk.Actor {
+	return ModuleAccount(stakingModuleName, append([]byte("removed"), pubKey...))
+}
+
+// load/save the coins bonded by the genesis validators
+func loadGenesisCoins(store state.SimpleDB) (genesisCoins []GenesisCoins) {
+	b := store.Get(GenesisCoinsKey)
+	if b == nil {
+		return
+	}
+
+	err := wire.ReadBinaryBytes(b, &genesisCoins)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
 	return
 }
+func saveGenesisCoins(store state.SimpleDB, genesisCoins []GenesisCoins) {
+	store.Set(GenesisCoinsKey, wire.BinaryBytes(genesisCoins))
+}
 
-func saveBonds(store state.SimpleDB, validatorBonds ValidatorBonds) {
-	b := wire.BinaryBytes(validatorBonds)
-	store.Set(BondKey, b)
+// load/save the current validator set
+func loadValidatorSet(store state.SimpleDB) (validators []*abci.Validator) {
+	b := store.Get(ValidatorSetKey)
+	if b == nil {
+		return
+	}
+
+	err := wire.ReadBinaryBytes(b, &validators)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+
+	return
+}
+func saveValidatorSet(store state.SimpleDB, validators []*abci.Validator) {
+	b := wire.BinaryBytes(validators)
+	store.Set(ValidatorSetKey, b)
+}
+
+// load/save the validator set of the next epoch, it is only written when it
+// changes
+func loadNextValidatorSet(store state.SimpleDB) (validators []*abci.Validator) {
+	b := store.Get(NextValidatorSetKey)
+	if b == nil {
+		return
+	}
+
+	err := wire.ReadBinaryBytes(b, &validators)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+
+	return
+}
+func saveNextValidatorSet(store state.SimpleDB, validators []*abci.Validator) {
+	b := wire.BinaryBytes(validators)
+	if !bytes.Equal(store.Get(NextValidatorSetKey), b) {
+		store.Set(NextValidatorSetKey, b)
+	}
+}
+
+// load/save/remove a delegator bond
+func loadDelegatorBond(store state.SimpleDB,
+	delegator sdk.Actor, pubKey []byte) *DelegatorBond {
+
+	b := store.Get(DelegatorBondKey(delegator, pubKey))
+	if b == nil {
+		return nil
+	}
+
+	bond := new(DelegatorBond)
+	err := wire.ReadBinaryBytes(b, bond)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+
+	return bond
+}
+
+// load all the bonds of a delegator, to any validator
+func loadDelegatorBonds(store state.SimpleDB, delegator sdk.Actor) (bonds []*DelegatorBond) {
+	start := delegatorBondsKey(delegator)
+	for _, model := range store.List(start, prefixEnd(start), 0) {
+		bond := new(DelegatorBond)
+		err := wire.ReadBinaryBytes(model.Value, bond)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		bonds = append(bonds, bond)
+	}
+	return
+}
+
+// BondedCoins - the coins a delegator has bonded to validators, its own
+// validator included, at the exchange rate of each validator
+func BondedCoins(store state.SimpleDB, delegator sdk.Actor) (coins uint64) {
+	for _, delegatorBond := range loadDelegatorBonds(store, delegator) {
+		bond := loadValidatorBondByPubKey(store, delegatorBond.PubKey)
+		if bond != nil {
+			coins += bond.CoinsFromTokens(delegatorBond.BondedTokens)
+		}
+	}
+	return
+}
+
+// TotalBondedCoins - the coins bonded to all the validator bonds
+func TotalBondedCoins(store state.SimpleDB) (coins uint64) {
+	for _, bond := range LoadBonds(store) {
+		coins += bond.BondedCoins
+	}
+	return
+}
+
+func saveDelegatorBond(store state.SimpleDB, bond *DelegatorBond) {
+	b := wire.BinaryBytes(*bond)
+	store.Set(DelegatorBondKey(bond.Delegator, bond.PubKey), b)
+}
+func removeDelegatorBond(store state.SimpleDB, delegator sdk.Actor, pubKey []byte) {
+	store.Remove(DelegatorBondKey(delegator, pubKey))
+}
+
+// UnbondingQueueKey - state key for coins unbonding from a validator, the
+// keys are ordered by the height the coins are released at
+func UnbondingQueueKey(heightRelease uint64, delegator sdk.Actor, pubKey []byte) []byte {
+	key := append(unbondingQueueHeightKey(heightRelease), wire.BinaryBytes(&delegator)...)
+	return append(key, pubKey...)
+}
+
+func unbondingQueueHeightKey(height uint64) []byte {
+	key := make([]byte, len(UnbondingQueueKeyPrefix)+8)
+	copy(key, UnbondingQueueKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(UnbondingQueueKeyPrefix):], height)
+	return key
+}
+
+// load the unbonding queue elements released at or before the height
+func loadUnbondingQueue(store state.SimpleDB, height uint64) []*UnbondingQueueElem {
+	return listUnbondingQueue(store, unbondingQueueHeightKey(height+1))
+}
+
+// load every element of the unbonding queue
+func loadFullUnbondingQueue(store state.SimpleDB) []*UnbondingQueueElem {
+	end := []byte{UnbondingQueueKeyPrefix[0] + 1}
+	return listUnbondingQueue(store, end)
+}
+
+func listUnbondingQueue(store state.SimpleDB, end []byte) (queue []*UnbondingQueueElem) {
+	start := unbondingQueueHeightKey(0)
+	for _, model := range store.List(start, end, 0) {
+		elem := new(UnbondingQueueElem)
+		err := wire.ReadBinaryBytes(model.Value, elem)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		queue = append(queue, elem)
+	}
+	return
+}
+
+// add coins to the unbonding queue, merging with any element already
+// unbonding for the same delegator and validator at the same height
+func pushUnbondingQueue(store state.SimpleDB, elem UnbondingQueueElem) {
+	key := UnbondingQueueKey(elem.HeightRelease, elem.Delegator, elem.PubKey)
+	if b := store.Get(key); b != nil {
+		var existing UnbondingQueueElem
+		err := wire.ReadBinaryBytes(b, &existing)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		elem.Amount += existing.Amount
+	}
+	store.Set(key, wire.BinaryBytes(elem))
+}
+
+func saveUnbondingQueueElem(store state.SimpleDB, elem *UnbondingQueueElem) {
+	key := UnbondingQueueKey(elem.HeightRelease, elem.Delegator, elem.PubKey)
+	store.Set(key, wire.BinaryBytes(*elem))
+}
+
+func removeUnbondingQueueElem(store state.SimpleDB, elem *UnbondingQueueElem) {
+	store.Remove(UnbondingQueueKey(elem.HeightRelease, elem.Delegator, elem.PubKey))
+}
+
+// RedelegationQueueKey - state key for coins redelegated between validators
+func RedelegationQueueKey(heightRelease uint64, delegator sdk.Actor,
+	pubKeyFrom, pubKeyTo []byte) []byte {
+
+	key := append(redelegationQueueHeightKey(heightRelease), wire.BinaryBytes(&delegator)...)
+	key = append(key, pubKeyFrom...)
+	return append(key, pubKeyTo...)
+}
+
+func redelegationQueueHeightKey(height uint64) []byte {
+	key := make([]byte, len(RedelegationKeyPrefix)+8)
+	copy(key, RedelegationKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(RedelegationKeyPrefix):], height)
+	return key
+}
+
+// load the redelegations which can no longer be slashed at the height
+func loadRedelegationQueue(store state.SimpleDB, height uint64) []*RedelegationQueueElem {
+	return listRedelegationQueue(store, redelegationQueueHeightKey(height+1))
+}
+
+// load every redelegation which can still be slashed
+func loadFullRedelegationQueue(store state.SimpleDB) []*RedelegationQueueElem {
+	end := []byte{RedelegationKeyPrefix[0] + 1}
+	return listRedelegationQueue(store, end)
+}
+
+func listRedelegationQueue(store state.SimpleDB, end []byte) (queue []*RedelegationQueueElem) {
+	start := redelegationQueueHeightKey(0)
+	for _, model := range store.List(start, end, 0) {
+		elem := new(RedelegationQueueElem)
+		err := wire.ReadBinaryBytes(model.Value, elem)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		queue = append(queue, elem)
+	}
+	return
+}
+
+// add redelegated coins to the queue, merging with any element already
+// redelegated by the same delegator between the same validators at the same height
+func pushRedelegationQueue(store state.SimpleDB, elem RedelegationQueueElem) {
+	key := RedelegationQueueKey(elem.HeightRelease, elem.Delegator, elem.PubKeyFrom, elem.PubKeyTo)
+	if b := store.Get(key); b != nil {
+		var existing RedelegationQueueElem
+		err := wire.ReadBinaryBytes(b, &existing)
+		if err != nil {
+			panic(err) // This error should never occure big problem if does
+		}
+		elem.Amount += existing.Amount
+	}
+	store.Set(key, wire.BinaryBytes(elem))
+}
+
+func saveRedelegationQueueElem(store state.SimpleDB, elem *RedelegationQueueElem) {
+	key := RedelegationQueueKey(elem.HeightRelease, elem.Delegator, elem.PubKeyFrom, elem.PubKeyTo)
+	store.Set(key, wire.BinaryBytes(*elem))
+}
+
+func removeRedelegationQueueElem(store state.SimpleDB, elem *RedelegationQueueElem) {
+	store.Remove(RedelegationQueueKey(elem.HeightRelease, elem.Delegator, elem.PubKeyFrom, elem.PubKeyTo))
+}
+
+// load/save the state of the block provisions
+func loadProvisions(store state.SimpleDB) (provisions Provisions) {
+	b := store.Get(ProvisionsKey)
+	if b == nil {
+		return initialProvisions(loadParams(store))
+	}
+
+	err := wire.ReadBinaryBytes(b, &provisions)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+
+	return
+}
+func saveProvisions(store state.SimpleDB, provisions Provisions) {
+	b := wire.BinaryBytes(provisions)
+	store.Set(ProvisionsKey, b)
+}
+
+// load/save/remove the signing info of a validator
+func loadSigningInfo(store state.SimpleDB, pubKey []byte) *SigningInfo {
+	b := store.Get(SigningInfoKey(pubKey))
+	if b == nil {
+		return nil
+	}
+
+	info := new(SigningInfo)
+	err := wire.ReadBinaryBytes(b, info)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+
+	return info
+}
+func saveSigningInfo(store state.SimpleDB, info *SigningInfo) {
+	b := wire.BinaryBytes(*info)
+	store.Set(SigningInfoKey(info.PubKey), b)
+}
+func removeSigningInfo(store state.SimpleDB, pubKey []byte) {
+	store.Remove(SigningInfoKey(pubKey))
+}
+
+// load/save the pubkeys of the validator set in the order of tendermint
+func loadSigners(store state.SimpleDB) (signers [][]byte) {
+	b := store.Get(SignersKey)
+	if b == nil {
+		return
+	}
+
+	err := wire.ReadBinaryBytes(b, &signers)
+	if err != nil {
+		panic(err) // This error should never occure big problem if does
+	}
+
+	return
+}
+func saveSigners(store state.SimpleDB, signers [][]byte) {
+	b := wire.BinaryBytes(signers)
+	store.Set(SignersKey, b)
+}
+
+// LoadParams - the global staking params, for the modules the params are
+// changed by
+func LoadParams(store state.SimpleDB) Params {
+	return loadParams(store)
 }
 
 // load/save the global staking params
@@ -1,11 +1,19 @@
 package stake
 
 import (
+	"bytes"
+	"fmt"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/go-wire"
 
 	"github.com/cosmos/cosmos-sdk"
+	"github.com/cosmos/cosmos-sdk/modules/coin"
 	"github.com/cosmos/cosmos-sdk/state"
 )
 
@@ -43,3 +51,285 @@ func TestState(t *testing.T) {
 	resGet = LoadBonds(store)
 	assert.Equal(validatorBonds, resGet)
 }
+
+func TestValidatorBondState(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	actors := newActors(3)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{100, 300, 200}))
+	saveBonds(store, bonds)
+
+	// the bonds can be loaded by sender and by pubkey
+	bond := loadValidatorBond(store, actors[1])
+	require.NotNil(bond)
+	assert.Equal(bonds[1], bond)
+	assert.Equal(bonds[2], loadValidatorBondByPubKey(store, actors[2].Address))
+	assert.Nil(loadValidatorBond(store, validator))
+	assert.Nil(loadValidatorBondByPubKey(store, []byte("unknown")))
+
+	// the index by pubkey holds the key of the bond, as on older chains
+	assert.Equal(ValidatorKey(actors[2]), store.Get(ValidatorPubKeyKey(actors[2].Address)))
+
+	// all the bonds are loaded ordered by voting power
+	loaded := LoadBonds(store)
+	require.Equal(3, len(loaded))
+	assert.Equal(actors[1], loaded[0].Sender)
+	assert.Equal(actors[2], loaded[1].Sender)
+	assert.Equal(actors[0], loaded[2].Sender)
+
+	// the power index follows changes of the voting power
+	bond.VotingPower = 50
+	saveValidatorBond(store, bond)
+	loaded = LoadBonds(store)
+	require.Equal(3, len(loaded))
+	assert.Equal(actors[1], loaded[2].Sender)
+
+	// removing a bond removes it from the indexes
+	removeValidatorBond(store, bond)
+	assert.Nil(loadValidatorBond(store, actors[1]))
+	assert.Nil(loadValidatorBondByPubKey(store, actors[1].Address))
+	assert.Equal(2, len(LoadBonds(store)))
+	assert.Equal([]sdk.Actor{actors[0], actors[2]}, sortedSenders(loadValidatorList(store)))
+}
+
+func TestValidatorList(t *testing.T) {
+	assert := assert.New(t)
+
+	actors := newActors(4)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{100, 300, 200, 400}))
+
+	// the list holds every sender once, in the same order whatever the order
+	// the bonds were saved in
+	store, reversed := state.NewMemKVStore(), state.NewMemKVStore()
+	saveBonds(store, bonds)
+	saveBonds(store, bonds)
+	for i := len(bonds) - 1; i >= 0; i-- {
+		saveValidatorBond(reversed, bonds[i])
+	}
+	list := loadValidatorList(store)
+	assert.Equal(4, len(list))
+	assert.Equal(list, loadValidatorList(reversed))
+	assert.Equal(list, sortedSenders(list))
+
+	// the list is removed with the last bond
+	for _, bond := range bonds {
+		removeValidatorBond(store, bond)
+	}
+	assert.Nil(store.Get(ValidatorListKey))
+}
+
+func sortedSenders(senders []sdk.Actor) []sdk.Actor {
+	sorted := append([]sdk.Actor{}, senders...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(wire.BinaryBytes(&sorted[i]), wire.BinaryBytes(&sorted[j])) < 0
+	})
+	return sorted
+}
+
+func TestMigrateBonds(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	actors := newActors(3)
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{300, 200, 100}))
+	params := defaultParams()
+	params.MaxVals = 2
+
+	// the bonds stored as a single list in the legacy layout are moved to their
+	// own keys, and the params are moved to the current layout
+	var legacyBonds []*legacyValidatorBond
+	for _, bond := range bonds {
+		legacyBonds = append(legacyBonds, &legacyValidatorBond{
+			bond.Sender, bond.PubKey, bond.BondedTokens, bond.HoldAccount, bond.VotingPower})
+	}
+	store.Set(BondKey, wire.BinaryBytes(legacyBonds))
+	store.Set(ParamKey, wire.BinaryBytes(legacyParams{2, "fermion", 20, 0}))
+	MigrateBonds(store)
+	assert.Nil(store.Get(BondKey))
+	assert.Equal(params, loadParams(store))
+	assert.Equal(bonds, LoadBonds(store))
+	require.Equal(2, len(loadValidatorSet(store)))
+	assert.Equal(bonds[0].PubKey, loadValidatorSet(store)[0].PubKey)
+
+	// each sender holds the tokens of its bond
+	for _, bond := range bonds {
+		delegatorBond := loadDelegatorBond(store, bond.Sender, bond.PubKey)
+		require.NotNil(delegatorBond)
+		assert.Equal(bond.BondedTokens, delegatorBond.BondedTokens)
+	}
+
+	// migrating again does nothing
+	MigrateBonds(store)
+	assert.Equal(bonds, LoadBonds(store))
+}
+
+// the hold account of older chains, the address of the sender with the first
+// byte zeroed
+func oldHoldAccount(sender sdk.Actor) sdk.Actor {
+	return sdk.NewActor(stakingModuleName, append([]byte{0x00}, sender.Address[1:]...))
+}
+
+func TestMigrateHoldAccounts(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	// two validators share the old hold account, with coins unbonding from one
+	// of them and from a validator which has been removed
+	store := state.NewMemKVStore()
+	actors := []sdk.Actor{sdk.NewActor("sigs", []byte("1address")), sdk.NewActor("sigs", []byte("2address"))}
+	bonds := ValidatorBonds(bondsFromActors(actors, []int{300, 200}))
+	old := oldHoldAccount(actors[0])
+	for _, bond := range bonds {
+		bond.HoldAccount = old
+	}
+	saveBonds(store, bonds)
+	unbonding := UnbondingQueueElem{Delegator: actors[1], PubKey: bonds[0].PubKey,
+		HoldAccount: old, Amount: 50, HeightRelease: 10}
+	removed := UnbondingQueueElem{Delegator: actors[1], PubKey: []byte("removed"),
+		HoldAccount: old, Amount: 30, HeightRelease: 20}
+	pushUnbondingQueue(store, unbonding)
+	pushUnbondingQueue(store, removed)
+	accStore := map[string]int64{string(old.Address): 580}
+
+	// a failed transfer leaves the migration to be run again
+	failTransferFn := func(from, to sdk.Actor, coins coin.Coins) abci.Result {
+		return abci.ErrInsufficientFunds
+	}
+	assert.NotNil(migrateHoldAccounts(store, failTransferFn))
+	assert.Nil(store.Get(HoldAccountsMigratedKey))
+
+	// each validator gets its own coins, the coins unbonding from the removed
+	// validator are moved to the account of its pubkey, emptying the old one
+	require.Nil(migrateHoldAccounts(store, dummyTransferFn(accStore)))
+	assert.NotNil(store.Get(HoldAccountsMigratedKey))
+	holders := []sdk.Actor{getHoldAccount(actors[0]), getHoldAccount(actors[1])}
+	removedHolder := getRemovedHoldAccount(removed.PubKey)
+	assert.Equal(int64(350), accStore[string(holders[0].Address)])
+	assert.Equal(int64(200), accStore[string(holders[1].Address)])
+	assert.Equal(int64(30), accStore[string(removedHolder.Address)])
+	assert.Equal(int64(0), accStore[string(old.Address)])
+	assert.Equal(holders[0], loadValidatorBond(store, actors[0]).HoldAccount)
+	assert.Equal(holders[1], loadValidatorBond(store, actors[1]).HoldAccount)
+	queue := loadFullUnbondingQueue(store)
+	require.Equal(2, len(queue))
+	assert.Equal(holders[0], queue[0].HoldAccount)
+	assert.Equal(removedHolder, queue[1].HoldAccount)
+
+	// migrating again does nothing, the bonds are no longer checked
+	bond := loadValidatorBond(store, actors[1])
+	bond.HoldAccount = old
+	saveValidatorBond(store, bond)
+	require.Nil(migrateHoldAccounts(store, dummyTransferFn(accStore)))
+	assert.Equal(int64(350), accStore[string(holders[0].Address)])
+	assert.Equal(int64(0), accStore[string(old.Address)])
+	assert.Equal(old, loadValidatorBond(store, actors[1]).HoldAccount)
+
+	// the hold accounts of a chain started from a genesis are never migrated
+	genesisStore := state.NewMemKVStore()
+	require.Nil(Genesis{Params: defaultParams()}.apply(genesisStore))
+	assert.NotNil(genesisStore.Get(HoldAccountsMigratedKey))
+}
+
+func TestUnbondingQueueState(t *testing.T) {
+	assert := assert.New(t)
+
+	store := state.NewMemKVStore()
+	delegator := sdk.Actor{"testChain", "testapp", []byte("addressdelegator1")}
+	holder := sdk.Actor{"testChain", "testapp", []byte("addresslockedtoapp")}
+
+	elem := func(amount, height uint64) UnbondingQueueElem {
+		return UnbondingQueueElem{
+			Delegator:     delegator,
+			PubKey:        []byte("pubkey1"),
+			HoldAccount:   holder,
+			Amount:        amount,
+			HeightRelease: height,
+		}
+	}
+
+	// the queue is empty to start with
+	assert.Empty(loadUnbondingQueue(store, 100))
+
+	// unbondings at the same height are merged, the queue is ordered by height
+	pushUnbondingQueue(store, elem(5, 20))
+	pushUnbondingQueue(store, elem(7, 10))
+	pushUnbondingQueue(store, elem(3, 10))
+
+	assert.Empty(loadUnbondingQueue(store, 9))
+	queue := loadUnbondingQueue(store, 100)
+	if assert.Equal(2, len(queue)) {
+		assert.Equal(uint64(10), queue[0].Amount)
+		assert.Equal(uint64(10), queue[0].HeightRelease)
+		assert.Equal(uint64(5), queue[1].Amount)
+	}
+
+	removeUnbondingQueueElem(store, queue[0])
+	assert.Equal(1, len(loadUnbondingQueue(store, 100)))
+}
+
+// update one validator bond, as each DeliverTx of a bond does
+func benchmarkUpdateBond(b *testing.B, numBonds int, legacy bool) {
+	store := state.NewMemKVStore()
+	actors := newActors(numBonds)
+	amts := make([]int, numBonds)
+	for i := range amts {
+		amts[i] = i + 1
+	}
+	bonds := ValidatorBonds(bondsFromActors(actors, amts))
+	if legacy {
+		store.Set(BondKey, wire.BinaryBytes(bonds))
+	} else {
+		saveBonds(store, bonds)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sender := actors[i%numBonds]
+		if legacy {
+			var loaded ValidatorBonds
+			err := wire.ReadBinaryBytes(store.Get(BondKey), &loaded)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, bond := loaded.Get(sender)
+			bond.BondedCoins++
+			store.Set(BondKey, wire.BinaryBytes(loaded))
+		} else {
+			bond := loadValidatorBond(store, sender)
+			bond.BondedCoins++
+			saveValidatorBond(store, bond)
+		}
+	}
+}
+
+func BenchmarkUpdateBond(b *testing.B) {
+	for _, numBonds := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("legacy-%d", numBonds), func(b *testing.B) {
+			benchmarkUpdateBond(b, numBonds, true)
+		})
+		b.Run(fmt.Sprintf("keyed-%d", numBonds), func(b *testing.B) {
+			benchmarkUpdateBond(b, numBonds, false)
+		})
+	}
+}
+
+func TestBondedCoins(t *testing.T) {
+	assert := assert.New(t)
+
+	store := state.NewMemKVStore()
+	actors := newActors(3)
+	bonds := ValidatorBonds(bondsFromActors(actors[:2], []int{100, 300}))
+	bonds[1].BondedCoins = 600 // two coins per token
+	saveBonds(store, bonds)
+
+	delegator := actors[2]
+	assert.Equal(uint64(0), BondedCoins(store, delegator))
+
+	// the bonds to each validator are summed at its exchange rate
+	saveDelegatorBond(store, &DelegatorBond{delegator, bonds[0].PubKey, 10})
+	saveDelegatorBond(store, &DelegatorBond{delegator, bonds[1].PubKey, 20})
+	saveDelegatorBond(store, &DelegatorBond{actors[0], bonds[1].PubKey, 50})
+	assert.Equal(uint64(50), BondedCoins(store, delegator))
+	assert.Equal(uint64(100), BondedCoins(store, actors[0]))
+	assert.Equal(uint64(700), TotalBondedCoins(store))
+}
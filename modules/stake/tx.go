@@ -1,8 +1,11 @@
 package stake
 
 import (
+	"bytes"
 	"fmt"
 
+	"github.com/tendermint/go-wire"
+
 	"github.com/cosmos/cosmos-sdk"
 	"github.com/cosmos/cosmos-sdk/modules/coin"
 )
@@ -14,34 +17,137 @@ import (
 // make sure to use the name of the handler as the prefix in the tx type,
 // so it gets routed properly
 const (
-	ByteTxBond   = 0x55
-	ByteTxUnbond = 0x56
-	TypeTxBond   = stakingModuleName + "/bond"
-	TypeTxUnbond = stakingModuleName + "/unbond"
+	ByteTxBond             = 0x55
+	ByteTxUnbond           = 0x56
+	ByteTxDelegate         = 0x57
+	ByteTxUnbondDelegation = 0x58
+	ByteTxUnjail           = 0x59
+	ByteTxEditValidator    = 0x5A
+	ByteTxDeclareCandidacy = 0x5B
+	ByteTxEditCandidacy    = 0x5C
+	ByteTxRedelegate       = 0x5D
+	TypeTxBond             = stakingModuleName + "/bond"
+	TypeTxUnbond           = stakingModuleName + "/unbond"
+	TypeTxDelegate         = stakingModuleName + "/delegate"
+	TypeTxUnbondDelegation = stakingModuleName + "/unbondDelegation"
+	TypeTxUnjail           = stakingModuleName + "/unjail"
+	TypeTxEditValidator    = stakingModuleName + "/editValidator"
+	TypeTxDeclareCandidacy = stakingModuleName + "/declareCandidacy"
+	TypeTxEditCandidacy    = stakingModuleName + "/editCandidacy"
+	TypeTxRedelegate       = stakingModuleName + "/redelegate"
 )
 
 func init() {
 	sdk.TxMapper.RegisterImplementation(TxBond{}, TypeTxBond, ByteTxBond)
 	sdk.TxMapper.RegisterImplementation(TxUnbond{}, TypeTxUnbond, ByteTxUnbond)
+	sdk.TxMapper.RegisterImplementation(TxDelegate{}, TypeTxDelegate, ByteTxDelegate)
+	sdk.TxMapper.RegisterImplementation(TxUnbondDelegation{}, TypeTxUnbondDelegation, ByteTxUnbondDelegation)
+	sdk.TxMapper.RegisterImplementation(TxUnjail{}, TypeTxUnjail, ByteTxUnjail)
+	sdk.TxMapper.RegisterImplementation(TxEditValidator{}, TypeTxEditValidator, ByteTxEditValidator)
+	sdk.TxMapper.RegisterImplementation(TxDeclareCandidacy{}, TypeTxDeclareCandidacy, ByteTxDeclareCandidacy)
+	sdk.TxMapper.RegisterImplementation(TxEditCandidacy{}, TypeTxEditCandidacy, ByteTxEditCandidacy)
+	sdk.TxMapper.RegisterImplementation(TxRedelegate{}, TypeTxRedelegate, ByteTxRedelegate)
 }
 
 // Verify interface at compile time
-var _, _ sdk.TxInner = &TxBond{}, &TxUnbond{}
+var _, _, _, _, _, _, _, _, _ sdk.TxInner = &TxDeclareCandidacy{}, &TxEditCandidacy{},
+	&TxBond{}, &TxUnbond{}, &TxDelegate{}, &TxUnbondDelegation{}, &TxRedelegate{},
+	&TxUnjail{}, &TxEditValidator{}
+
+//--------------------------------------------------------------------------------
+// TxDeclareCandidacy
+
+// TxDeclareCandidacy - struct for declaring the sender as a validator
+// candidate, bonding its first coins. The signature proves the sender controls
+// the pubkey, see TxBond.
+type TxDeclareCandidacy struct {
+	Amount      coin.Coin   `json:"amount"`
+	PubKey      []byte      `json:"pubkey"`
+	Signature   []byte      `json:"signature"`
+	Description Description `json:"description"`
+	Commission  Commission  `json:"commission"`
+}
+
+// NewTxDeclareCandidacy - new TxDeclareCandidacy
+func NewTxDeclareCandidacy(amount coin.Coin, pubKey, signature []byte,
+	description Description, commission Commission) sdk.Tx {
+
+	return TxDeclareCandidacy{
+		Amount:      amount,
+		PubKey:      pubKey,
+		Signature:   signature,
+		Description: description,
+		Commission:  commission,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxDeclareCandidacy) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check for a pubkey, valid coins, description and commission
+func (tx TxDeclareCandidacy) ValidateBasic() error {
+	if len(tx.PubKey) == 0 {
+		return errValidatorEmpty
+	}
+	err := tx.Description.Validate()
+	if err != nil {
+		return err
+	}
+	err = tx.Commission.Validate()
+	if err != nil {
+		return err
+	}
+	return validateBasic(tx.Amount)
+}
+
+// TxEditCandidacy - struct for changing the description of the sender's
+// candidate, empty fields are left unchanged
+type TxEditCandidacy struct {
+	Description Description `json:"description"`
+}
+
+// NewTxEditCandidacy - new TxEditCandidacy
+func NewTxEditCandidacy(description Description) sdk.Tx {
+	return TxEditCandidacy{
+		Description: description,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxEditCandidacy) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check the description isn't empty, the length of the fields
+// is checked against the updated description
+func (tx TxEditCandidacy) ValidateBasic() error {
+	if tx.Description == (Description{}) {
+		return fmt.Errorf("Description must have a field to update")
+	}
+	return nil
+}
 
 //--------------------------------------------------------------------------------
 // TxBond
 
-// TxBond - struct for bonding transactions
+// TxBond - struct for bonding more coins to the sender's candidate. The
+// optional signature is a proof of possession of the validator pubkey, it is
+// the sender address signed with the private key of the validator, see
+// PossessionSignBytes.
 type TxBond struct {
-	Amount coin.Coin `json:"amount"`
-	PubKey []byte    `json:"pubkey"`
+	Amount    coin.Coin `json:"amount"`
+	PubKey    []byte    `json:"pubkey"`
+	Signature []byte    `json:"signature"`
 }
 
 // NewTxBond - new TxBond
-func NewTxBond(amount coin.Coin, pubKey []byte) sdk.Tx {
+func NewTxBond(amount coin.Coin, pubKey, signature []byte) sdk.Tx {
 	return TxBond{
-		Amount: amount,
-		PubKey: pubKey,
+		Amount:    amount,
+		PubKey:    pubKey,
+		Signature: signature,
 	}.Wrap()
 }
 
@@ -77,6 +183,152 @@ func (tx TxUnbond) ValidateBasic() error {
 	return validateBasic(tx.Amount)
 }
 
+// TxDelegate - struct for bonding coins to a validator owned by another account
+type TxDelegate struct {
+	Amount coin.Coin `json:"amount"`
+	PubKey []byte    `json:"pubkey"`
+}
+
+// NewTxDelegate - new TxDelegate
+func NewTxDelegate(amount coin.Coin, pubKey []byte) sdk.Tx {
+	return TxDelegate{
+		Amount: amount,
+		PubKey: pubKey,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxDelegate) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check for a validator pubkey, and valid coins
+func (tx TxDelegate) ValidateBasic() error {
+	if len(tx.PubKey) == 0 {
+		return errValidatorEmpty
+	}
+	return validateBasic(tx.Amount)
+}
+
+// TxUnbondDelegation - struct for unbonding delegated coins from a validator
+type TxUnbondDelegation struct {
+	Amount coin.Coin `json:"amount"`
+	PubKey []byte    `json:"pubkey"`
+}
+
+// NewTxUnbondDelegation - new TxUnbondDelegation
+func NewTxUnbondDelegation(amount coin.Coin, pubKey []byte) sdk.Tx {
+	return TxUnbondDelegation{
+		Amount: amount,
+		PubKey: pubKey,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxUnbondDelegation) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check for a validator pubkey, and valid coins
+func (tx TxUnbondDelegation) ValidateBasic() error {
+	if len(tx.PubKey) == 0 {
+		return errValidatorEmpty
+	}
+	return validateBasic(tx.Amount)
+}
+
+// TxRedelegate - struct for moving delegated coins from one validator to
+// another without waiting for the unbonding period
+type TxRedelegate struct {
+	Amount     coin.Coin `json:"amount"`
+	PubKeyFrom []byte    `json:"pubkey_from"`
+	PubKeyTo   []byte    `json:"pubkey_to"`
+}
+
+// NewTxRedelegate - new TxRedelegate
+func NewTxRedelegate(amount coin.Coin, pubKeyFrom, pubKeyTo []byte) sdk.Tx {
+	return TxRedelegate{
+		Amount:     amount,
+		PubKeyFrom: pubKeyFrom,
+		PubKeyTo:   pubKeyTo,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxRedelegate) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check for two different validator pubkeys, and valid coins
+func (tx TxRedelegate) ValidateBasic() error {
+	if len(tx.PubKeyFrom) == 0 || len(tx.PubKeyTo) == 0 {
+		return errValidatorEmpty
+	}
+	if bytes.Equal(tx.PubKeyFrom, tx.PubKeyTo) {
+		return errRedelegateSameValidator
+	}
+	return validateBasic(tx.Amount)
+}
+
+// TxUnjail - struct for returning the sender's validator to the validator set
+// once its jail period for downtime has passed
+type TxUnjail struct{}
+
+// NewTxUnjail - new TxUnjail
+func NewTxUnjail() sdk.Tx {
+	return TxUnjail{}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxUnjail) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - nothing to check, the sender is the validator
+func (tx TxUnjail) ValidateBasic() error {
+	return nil
+}
+
+// TxEditValidator - struct for changing the commission rate of the sender's
+// validator, within the limits set when it was created
+type TxEditValidator struct {
+	Commission Fraction `json:"commission"`
+}
+
+// NewTxEditValidator - new TxEditValidator
+func NewTxEditValidator(commission Fraction) sdk.Tx {
+	return TxEditValidator{
+		Commission: commission,
+	}.Wrap()
+}
+
+// Wrap - Wrap a Tx as a Basecoin Tx
+func (tx TxEditValidator) Wrap() sdk.Tx {
+	return sdk.Tx{tx}
+}
+
+// ValidateBasic - Check the commission rate is between 0 and 1
+func (tx TxEditValidator) ValidateBasic() error {
+	one := NewFraction(1, 1)
+	return NewCommission(tx.Commission, one, one).Validate()
+}
+
+// the prefix of the bytes signed to prove the possession of a validator pubkey,
+// so no signature made for another purpose is valid
+const possessionSignTag = "gaia/stake/possession"
+
+type possessionSignDoc struct {
+	Tag     string
+	ChainID string
+	Sender  sdk.Actor
+}
+
+// PossessionSignBytes - the bytes the validator key signs to prove the sender
+// controls the validator pubkey, bound to the chain
+func PossessionSignBytes(chainID string, sender sdk.Actor) []byte {
+	return wire.BinaryBytes(possessionSignDoc{possessionSignTag, chainID, sender})
+}
+
 func validateBasic(amount coin.Coin) error {
 	coins := coin.Coins{amount}
 	if !coins.IsValid() {
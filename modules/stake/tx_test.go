@@ -46,3 +46,30 @@ func TestBondUpdateValidateBasic(t *testing.T) {
 		})
 	}
 }
+
+func TestCommissionValidate(t *testing.T) {
+	half, one := NewFraction(1, 2), NewFraction(1, 1)
+
+	tests := []struct {
+		name       string
+		commission Commission
+		wantErr    bool
+	}{
+		{"empty", Commission{}, false},
+		{"basic good", NewCommission(NewFraction(1, 10), half, NewFraction(1, 100)), false},
+		{"rate at max", NewCommission(half, half, half), false},
+		{"negative rate", NewCommission(NewFraction(-1, 10), half, half), true},
+		{"negative max change", NewCommission(half, half, NewFraction(-1, 10)), true},
+		{"huge max", NewCommission(half, NewFraction(3, 2), half), true},
+		{"rate above max", NewCommission(one, half, half), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, tt.commission.Validate() != nil, tt.name)
+		})
+	}
+
+	assert.Equal(t, errCommissionNegative, NewCommission(NewFraction(-1, 10), half, half).Validate())
+	assert.Equal(t, errCommissionHuge, NewCommission(half, NewFraction(3, 2), half).Validate())
+}
@@ -3,6 +3,7 @@ package stake
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"sort"
 
 	abci "github.com/tendermint/abci/types"
@@ -12,11 +13,43 @@ import (
 	"github.com/cosmos/cosmos-sdk/state"
 )
 
-// Params defines the high level settings for staking
+// Params defines the high level settings for staking. The JSON field names are
+// the keys of the genesis options and the output of `gaiacli query stake-params`,
+// scripts rely on them so they must not change.
 type Params struct {
 	MaxVals          int    `json:"max_vals"`           // maximum number of validators
 	AllowedBondDenom string `json:"allowed_bond_denom"` // bondable coin denomination
 
+	// number of blocks unbonded coins are held before being returned
+	UnbondingPeriod uint64 `json:"unbonding_period"`
+
+	// block provisions, inflation moves between the min and max annual rates
+	// depending on how close the bonded ratio is to the goal
+	InflationRateMin Fraction `json:"inflation_rate_min"`
+	InflationRateMax Fraction `json:"inflation_rate_max"`
+	GoalBonded       Fraction `json:"goal_bonded"`
+	TotalSupply      uint64   `json:"total_supply"`    // supply of the bond denom at genesis
+	BlocksPerYear    uint64   `json:"blocks_per_year"` // expected number of blocks in a year
+
+	// fraction of the bonded coins burned when a validator double signs
+	SlashFractionDoubleSign Fraction `json:"slash_fraction_double_sign"`
+
+	// validators signing less than the minimum of the blocks in the window are
+	// jailed for the jail period and slashed by the downtime fraction
+	SignedBlocksWindow    uint64   `json:"signed_blocks_window"`
+	MinSignedPerWindow    Fraction `json:"min_signed_per_window"`
+	DowntimeJailPeriod    uint64   `json:"downtime_jail_period"`
+	SlashFractionDowntime Fraction `json:"slash_fraction_downtime"`
+
+	// number of blocks over which the changes of voting power accumulate before
+	// the validator set is updated, at the end of each epoch
+	EpochLength uint64 `json:"epoch_length"`
+
+	// fraction of the total voting power of the validator set which may change
+	// when the set is updated, the rest of the changes is carried forward to
+	// the next updates
+	MaxPowerChangePerBlock Fraction `json:"max_power_change_per_block"`
+
 	// gas costs for txs
 	GasBond   uint64 `json:"gas_bond"`
 	GasUnbond uint64 `json:"gas_unbond"`
@@ -26,9 +59,230 @@ func defaultParams() Params {
 	return Params{
 		MaxVals:          100,
 		AllowedBondDenom: "fermion",
-		GasBond:          20,
-		GasUnbond:        0,
+		UnbondingPeriod:  100,
+		InflationRateMin: NewFraction(7, 100),
+		InflationRateMax: NewFraction(20, 100),
+		GoalBonded:       NewFraction(67, 100),
+		TotalSupply:      0,
+		BlocksPerYear:    6311520, // one block every 5 seconds
+
+		SlashFractionDoubleSign: NewFraction(5, 100),
+
+		SignedBlocksWindow:    100,
+		MinSignedPerWindow:    NewFraction(1, 2),
+		DowntimeJailPeriod:    600,
+		SlashFractionDowntime: NewFraction(1, 100),
+
+		EpochLength:            1, // the validator set is updated every block
+		MaxPowerChangePerBlock: NewFraction(1, 3),
+
+		GasBond:   20,
+		GasUnbond: 0,
+	}
+}
+
+// Validate - check the params as a whole, as set by the stake genesis
+func (p Params) Validate() error {
+	err := p.validateFields()
+	if err != nil {
+		return err
+	}
+	if p.InflationRateMin.Rat().Cmp(p.InflationRateMax.Rat()) > 0 {
+		return fmt.Errorf("inflation_rate_min must not be more than inflation_rate_max")
+	}
+	return nil
+}
+
+// check each param on its own, without the checks between params
+func (p Params) validateFields() error {
+	if p.MaxVals <= 0 {
+		return fmt.Errorf("max_vals must be positive, got %v", p.MaxVals)
+	}
+	if len(p.AllowedBondDenom) == 0 {
+		return fmt.Errorf("allowed_bond_denom must be set")
+	}
+	if p.BlocksPerYear == 0 {
+		return fmt.Errorf("blocks_per_year must be positive")
+	}
+	if p.SignedBlocksWindow == 0 {
+		return fmt.Errorf("signed_blocks_window must be positive")
+	}
+	if p.EpochLength == 0 {
+		return fmt.Errorf("epoch_length must be positive")
+	}
+
+	fractions := []struct {
+		key string
+		f   Fraction
+	}{
+		{"inflation_rate_min", p.InflationRateMin},
+		{"inflation_rate_max", p.InflationRateMax},
+		{"goal_bonded", p.GoalBonded},
+		{"slash_fraction_double_sign", p.SlashFractionDoubleSign},
+		{"min_signed_per_window", p.MinSignedPerWindow},
+		{"slash_fraction_downtime", p.SlashFractionDowntime},
+		{"max_power_change_per_block", p.MaxPowerChangePerBlock},
+	}
+	for _, fraction := range fractions {
+		f := fraction.f
+		if f.Denom <= 0 || f.Num < 0 || f.Num > f.Denom {
+			return fmt.Errorf("%v must be between 0 and 1, got %v/%v", fraction.key, f.Num, f.Denom)
+		}
+	}
+	if p.MaxPowerChangePerBlock.Num == 0 {
+		return fmt.Errorf("max_power_change_per_block must be positive")
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------
+
+// Fraction - a rational number, used for rates and ratios
+type Fraction struct {
+	Num   int64 `json:"num"`
+	Denom int64 `json:"denom"`
+}
+
+// NewFraction - returns a new fraction num/denom
+func NewFraction(num, denom int64) Fraction {
+	return Fraction{
+		Num:   num,
+		Denom: denom,
+	}
+}
+
+// ParseFraction - parse a fraction from either the "num/denom" or the
+// decimal representation
+func ParseFraction(str string) (f Fraction, err error) {
+	r, ok := new(big.Rat).SetString(str)
+	if !ok {
+		return f, fmt.Errorf("invalid fraction %q", str)
+	}
+	if !r.Num().IsInt64() || !r.Denom().IsInt64() {
+		return f, fmt.Errorf("fraction %q is out of range", str)
 	}
+	return NewFraction(r.Num().Int64(), r.Denom().Int64()), nil
+}
+
+// fractionPrecision - denominator for fractions derived from the state
+const fractionPrecision = 1000000000
+
+// fractionFromRat - the fraction approximated to fractionPrecision
+func fractionFromRat(r *big.Rat) Fraction {
+	num := new(big.Int).Mul(r.Num(), big.NewInt(fractionPrecision))
+	num.Quo(num, r.Denom())
+	return NewFraction(num.Int64(), fractionPrecision)
+}
+
+// Rat - the fraction as a big.Rat, a zero denominator is treated as zero
+func (f Fraction) Rat() *big.Rat {
+	if f.Denom == 0 {
+		return new(big.Rat)
+	}
+	return big.NewRat(f.Num, f.Denom)
+}
+
+func (f Fraction) String() string {
+	return f.Rat().RatString()
+}
+
+//--------------------------------------------------------------------------------
+
+// nolint - maximum lengths of the description fields
+const (
+	MaxMonikerLength  = 70
+	MaxIdentityLength = 3000
+	MaxWebsiteLength  = 140
+	MaxDetailsLength  = 280
+)
+
+// Description - the public information about a validator candidate
+type Description struct {
+	Moniker  string `json:"moniker"`  // name of the validator
+	Identity string `json:"identity"` // optional identity signature, e.g. a keybase.io key
+	Website  string `json:"website"`  // optional website link
+	Details  string `json:"details"`  // optional details
+}
+
+// NewDescription - returns a new description
+func NewDescription(moniker, identity, website, details string) Description {
+	return Description{
+		Moniker:  moniker,
+		Identity: identity,
+		Website:  website,
+		Details:  details,
+	}
+}
+
+// Update - the description with the non-empty fields of d2 replacing its own
+func (d Description) Update(d2 Description) Description {
+	if d2.Moniker != "" {
+		d.Moniker = d2.Moniker
+	}
+	if d2.Identity != "" {
+		d.Identity = d2.Identity
+	}
+	if d2.Website != "" {
+		d.Website = d2.Website
+	}
+	if d2.Details != "" {
+		d.Details = d2.Details
+	}
+	return d
+}
+
+// Validate - a moniker is required, and no field may be too long
+func (d Description) Validate() error {
+	if d.Moniker == "" {
+		return errCandidateEmptyMoniker
+	}
+	switch {
+	case len(d.Moniker) > MaxMonikerLength:
+		return fmt.Errorf("Moniker is longer than %v characters", MaxMonikerLength)
+	case len(d.Identity) > MaxIdentityLength:
+		return fmt.Errorf("Identity is longer than %v characters", MaxIdentityLength)
+	case len(d.Website) > MaxWebsiteLength:
+		return fmt.Errorf("Website is longer than %v characters", MaxWebsiteLength)
+	case len(d.Details) > MaxDetailsLength:
+		return fmt.Errorf("Details are longer than %v characters", MaxDetailsLength)
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------------
+
+// Commission - the share of the rewards a validator keeps before distributing
+// them to its delegators, and the limits on how it may be changed later on
+type Commission struct {
+	Rate      Fraction `json:"rate"`       // current commission rate
+	Max       Fraction `json:"max"`        // maximum commission rate, fixed at creation
+	MaxChange Fraction `json:"max_change"` // maximum change of the rate per day
+}
+
+// NewCommission - returns a new commission
+func NewCommission(rate, max, maxChange Fraction) Commission {
+	return Commission{
+		Rate:      rate,
+		Max:       max,
+		MaxChange: maxChange,
+	}
+}
+
+// Validate - each rate must be between 0 and 1, and the rate at most the max
+func (c Commission) Validate() error {
+	one := big.NewRat(1, 1)
+	for _, f := range []Fraction{c.Rate, c.Max, c.MaxChange} {
+		if f.Denom < 0 || f.Num < 0 {
+			return errCommissionNegative
+		}
+		if f.Rat().Cmp(one) > 0 {
+			return errCommissionHuge
+		}
+	}
+	if c.Rate.Rat().Cmp(c.Max.Rat()) > 0 {
+		return fmt.Errorf("Commission cannot be more than the max commission %v", c.Max)
+	}
+	return nil
 }
 
 //--------------------------------------------------------------------------------
@@ -44,8 +298,16 @@ type ValidatorBond struct {
 	Sender       sdk.Actor // Sender of BondTx - UnbondTx returns here
 	PubKey       []byte    // Pubkey of validator
 	BondedTokens uint64    // Total number of bond tokens for the validator
+	BondedCoins  uint64    // Total number of coins backing the bond tokens
 	HoldAccount  sdk.Actor // Account where the bonded coins are held. Controlled by the app
-	VotingPower  uint64    // Total number of bond tokens for the validator
+	VotingPower  uint64    // Bond tokens multiplied by the exchange rate
+	Revoked      bool      // Slashed for byzantine behaviour, never validates again
+	Jailed       bool      // Jailed for downtime, until unjailed by the sender
+	JailedUntil  uint64    // Height from which a jailed validator may be unjailed
+
+	Description            Description // Public information about the validator
+	Commission             Commission  // Share of the rewards kept by the sender
+	CommissionChangeHeight uint64      // Height of the last change of the commission rate
 }
 
 // NewValidatorBond - returns a new empty validator bond object
@@ -54,9 +316,85 @@ func NewValidatorBond(sender, holder sdk.Actor, pubKey []byte) *ValidatorBond {
 		Sender:       sender,
 		PubKey:       pubKey,
 		BondedTokens: 0,
+		BondedCoins:  0,
 		HoldAccount:  holder,
 		VotingPower:  0,
+		Revoked:      false,
+		Jailed:       false,
+		JailedUntil:  0,
+	}
+}
+
+// blocks in a day, the period over which the commission change is limited
+func (p Params) blocksPerDay() uint64 {
+	return p.BlocksPerYear / 365
+}
+
+// EditCommission - change the commission rate within the limits of the
+// commission, at most once a day and by at most the maximum change
+func (vb *ValidatorBond) EditCommission(rate Fraction, height uint64, params Params) error {
+	c := vb.Commission
+	err := Commission{rate, c.Max, c.MaxChange}.Validate()
+	if err != nil {
+		return err
 	}
+
+	if vb.CommissionChangeHeight > 0 && height < vb.CommissionChangeHeight+params.blocksPerDay() {
+		return fmt.Errorf("Commission can only be changed once a day, last changed at height %v",
+			vb.CommissionChangeHeight)
+	}
+	change := new(big.Rat).Sub(rate.Rat(), c.Rate.Rat())
+	if change.Abs(change).Cmp(c.MaxChange.Rat()) > 0 {
+		return fmt.Errorf("Commission cannot change by more than %v a day", c.MaxChange)
+	}
+
+	vb.Commission.Rate = rate
+	vb.CommissionChangeHeight = height
+	return nil
+}
+
+// ExchangeRate - the number of coins each bond token of the validator is
+// worth. Rewards added to BondedCoins raise the rate and slashing lowers it,
+// without modifying any of the bond tokens held by the delegators.
+func (vb ValidatorBond) ExchangeRate() *big.Rat {
+	if vb.BondedTokens == 0 {
+		return big.NewRat(1, 1)
+	}
+	return new(big.Rat).SetFrac(
+		new(big.Int).SetUint64(vb.BondedCoins),
+		new(big.Int).SetUint64(vb.BondedTokens))
+}
+
+// CoinsFromTokens - the number of coins a number of bond tokens are worth at
+// the current exchange rate, rounded down
+func (vb ValidatorBond) CoinsFromTokens(tokens uint64) uint64 {
+	if vb.BondedTokens == 0 {
+		return tokens
+	}
+	return mulDiv(tokens, vb.BondedCoins, vb.BondedTokens, false)
+}
+
+// TokensFromCoins - the number of bond tokens a number of coins are worth at
+// the current exchange rate. Rounding up should be used when the tokens are
+// being taken from a delegator, so that it never receives more than it holds.
+func (vb ValidatorBond) TokensFromCoins(coins uint64, roundUp bool) (uint64, error) {
+	if vb.BondedTokens == 0 {
+		return coins, nil
+	}
+	if vb.BondedCoins == 0 {
+		return 0, errNoExchangeRate
+	}
+	return mulDiv(coins, vb.BondedTokens, vb.BondedCoins, roundUp), nil
+}
+
+// calculate a * b / c without overflowing the intermediate product
+func mulDiv(a, b, c uint64, roundUp bool) uint64 {
+	num := new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+	div, mod := new(big.Int).DivMod(num, new(big.Int).SetUint64(c), new(big.Int))
+	if roundUp && mod.Sign() > 0 {
+		div.Add(div, big.NewInt(1))
+	}
+	return div.Uint64()
 }
 
 // ABCIValidator - Get the validator from a bond value
@@ -98,45 +436,50 @@ func (vbs ValidatorBonds) Sort() {
 	sort.Sort(vbs)
 }
 
-// UpdateVotingPower - voting power based on bond tokens and exchange rate
+// UpdateVotingPower - voting power based on bond tokens and exchange rate, the
+// power each validator is due. The changes of the validator set are limited
+// when it is updated, see UpdateValidatorSet
 // TODO: make not a function of ValidatorBonds as validatorbonds can be loaded from the store
 func (vbs ValidatorBonds) UpdateVotingPower(store state.SimpleDB) (changed bool) {
+	// remember the stored power, only the bonds whose power changes are saved
+	stored := make(map[*ValidatorBond]uint64, len(vbs))
 	for _, vb := range vbs {
-		if vb.VotingPower != vb.BondedTokens {
-			changed = true
-			vb.VotingPower = vb.BondedTokens
+		stored[vb] = vb.VotingPower
+		vb.VotingPower = vb.CoinsFromTokens(vb.BondedTokens)
+		if vb.Revoked || vb.Jailed {
+			vb.VotingPower = 0
 		}
 	}
 
-	// we don't write anything if nothing changes
-	if !changed {
-		return false
-	}
-
 	// Now sort and truncate the power
 	vbs.Sort()
+	maxVals := loadParams(store).MaxVals
 	for i, vb := range vbs {
-		if i >= loadParams(store).MaxVals {
+		if i >= maxVals {
 			vb.VotingPower = 0
 		}
 	}
 
-	saveBonds(store, vbs)
-	return true
+	for _, vb := range vbs {
+		if vb.VotingPower != stored[vb] {
+			changed = true
+			saveValidatorBond(store, vb)
+		}
+	}
+
+	// the validator set is updated at the end of the epoch, see
+	// UpdateValidatorSet
+	return changed
 }
 
-// CleanupEmpty - removes all validators which have no bonded atoms left
+// CleanupEmpty - removes all validators which have no bonded atoms left.
+// Revoked validators are kept so that their pubkey can never be bonded again.
 func (vbs ValidatorBonds) CleanupEmpty(store state.SimpleDB) {
-	for i, vb := range vbs {
-		if vb.BondedTokens == 0 {
-			var err error
-			vbs, err = vbs.Remove(i)
-			if err != nil {
-				cmn.PanicSanity(resBadRemoveValidator.Error())
-			}
+	for _, vb := range vbs {
+		if vb.BondedTokens == 0 && !vb.Revoked {
+			removeValidatorBond(store, vb)
 		}
 	}
-	saveBonds(store, vbs)
 }
 
 // GetValidators - get the most recent updated validator set from the
@@ -145,7 +488,7 @@ func (vbs ValidatorBonds) CleanupEmpty(store state.SimpleDB) {
 // is to modify the VotingPower
 func (vbs ValidatorBonds) GetValidators(store state.SimpleDB) []*abci.Validator {
 	maxVals := loadParams(store).MaxVals
-	validators := make([]*abci.Validator, cmn.MinInt(len(vbs), maxVals))
+	validators := make([]*abci.Validator, 0, cmn.MinInt(len(vbs), maxVals))
 	for i, vb := range vbs {
 		if vb.VotingPower == 0 { //exit as soon as the first Voting power set to zero is found
 			break
@@ -153,41 +496,43 @@ func (vbs ValidatorBonds) GetValidators(store state.SimpleDB) []*abci.Validator
 		if i >= maxVals {
 			return validators
 		}
-		validators[i] = vb.ABCIValidator()
+		validators = append(validators, vb.ABCIValidator())
 	}
 	return validators
 }
 
-// ValidatorsDiff - get the difference in the validator set from the input validator set
+// ValidatorsDiff - get the difference in the validator set from the input
+// validator set. The validators which changed power or left the set are listed
+// first, in their order in the previous set, followed by the validators which
+// joined the set, in their order in the current set.
 func ValidatorsDiff(previous, current []*abci.Validator, store state.SimpleDB) (diff []*abci.Validator) {
 
-	//TODO: do something more efficient possibly by sorting first
+	// index the validator sets by pubkey, so each set is only looped over once
+	currentPowers := make(map[string]uint64, len(current))
+	for _, curVal := range current {
+		if curVal == nil {
+			continue
+		}
+		if _, found := currentPowers[string(curVal.PubKey)]; !found {
+			currentPowers[string(curVal.PubKey)] = curVal.Power
+		}
+	}
+	inPrevious := make(map[string]bool, len(previous))
 
-	// calculate any differences from the previous to the new validator set
-	// first loop through the previous validator set, and then catch any
-	// missed records in the new validator set
 	diff = make([]*abci.Validator, 0, loadParams(store).MaxVals)
 
 	for _, prevVal := range previous {
 		if prevVal == nil {
 			continue
 		}
-		found := false
-		for _, curVal := range current {
-			if curVal == nil {
-				continue
-			}
-			if bytes.Equal(prevVal.PubKey, curVal.PubKey) {
-				found = true
-				if curVal.Power != prevVal.Power {
-					diff = append(diff, &abci.Validator{curVal.PubKey, curVal.Power})
-					break
-				}
-			}
-		}
-
-		if !found {
+		inPrevious[string(prevVal.PubKey)] = true
+
+		power, found := currentPowers[string(prevVal.PubKey)]
+		switch {
+		case !found:
 			diff = append(diff, &abci.Validator{prevVal.PubKey, 0})
+		case power != prevVal.Power:
+			diff = append(diff, &abci.Validator{prevVal.PubKey, power})
 		}
 	}
 
@@ -195,23 +540,10 @@ func ValidatorsDiff(previous, current []*abci.Validator, store state.SimpleDB) (
 		if curVal == nil {
 			continue
 		}
-
-		found := false
-		for _, prevVal := range previous {
-			if prevVal == nil {
-				continue
-			}
-			if bytes.Equal(prevVal.PubKey, curVal.PubKey) {
-				found = true
-				break
-			}
-		}
-
-		if !found {
+		if !inPrevious[string(curVal.PubKey)] {
 			diff = append(diff, &abci.Validator{curVal.PubKey, curVal.Power})
 		}
 	}
-
 	return
 }
 
@@ -253,3 +585,47 @@ func (vbs ValidatorBonds) Remove(i int) (ValidatorBonds, error) {
 		return append(vbs[:i], vbs[i+1:]...), nil
 	}
 }
+
+//--------------------------------------------------------------------------------
+
+// DelegatorBond represents some bond tokens held by an account. It is owned by
+// one delegator, and is associated with the voting power of one validator.
+// The bond tokens are worth coins at the validator's exchange rate.
+type DelegatorBond struct {
+	Delegator    sdk.Actor // Account which delegated - UnbondDelegationTx returns here
+	PubKey       []byte    // Pubkey of the validator bonded to
+	BondedTokens uint64    // Number of bond tokens held by the delegator
+}
+
+// NewDelegatorBond - returns a new empty delegator bond object
+func NewDelegatorBond(delegator sdk.Actor, pubKey []byte) *DelegatorBond {
+	return &DelegatorBond{
+		Delegator:    delegator,
+		PubKey:       pubKey,
+		BondedTokens: 0,
+	}
+}
+
+//--------------------------------------------------------------------------------
+
+// UnbondingQueueElem - coins which have been unbonded from a validator. They
+// no longer count towards its voting power, but remain in its hold account,
+// where they can still be slashed, until they are released at HeightRelease.
+type UnbondingQueueElem struct {
+	Delegator     sdk.Actor // Account the coins are returned to
+	PubKey        []byte    // Pubkey of the validator unbonded from
+	HoldAccount   sdk.Actor // Account where the coins are held until release
+	Amount        uint64    // Number of coins unbonding
+	HeightRelease uint64    // Height at which the coins are returned
+}
+
+// RedelegationQueueElem - coins which have been redelegated from a validator to
+// another. They are bonded to the new validator right away, but until
+// HeightRelease they are still slashed for the faults of the old validator.
+type RedelegationQueueElem struct {
+	Delegator     sdk.Actor // Account which redelegated
+	PubKeyFrom    []byte    // Pubkey of the validator redelegated from
+	PubKeyTo      []byte    // Pubkey of the validator redelegated to
+	Amount        uint64    // Number of coins redelegated
+	HeightRelease uint64    // Height until which the coins can be slashed
+}
@@ -1,12 +1,18 @@
 package stake
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	abci "github.com/tendermint/abci/types"
+
 	"github.com/cosmos/cosmos-sdk"
 	"github.com/cosmos/cosmos-sdk/state"
 )
@@ -28,6 +34,7 @@ func bondsFromActors(actors []sdk.Actor, amts []int) (bonds []*ValidatorBond) {
 			Sender:       a,
 			PubKey:       a.Address.Bytes(),
 			BondedTokens: uint64(amts[i]),
+			BondedCoins:  uint64(amts[i]),
 			HoldAccount:  getHoldAccount(a),
 			VotingPower:  uint64(amts[i]),
 		})
@@ -112,6 +119,7 @@ func TestValidatorBondsUpdate(t *testing.T) {
 	// Change some of the bonded tokens, get the new validator set
 	vals1 := bonds.GetValidators(store)
 	bonds[2].BondedTokens = 1000
+	bonds[2].BondedCoins = 1000
 	bonds.UpdateVotingPower(store)
 	vals2 := bonds.GetValidators(store)
 
@@ -130,3 +138,300 @@ func TestValidatorBondsUpdate(t *testing.T) {
 	assert.True(diff[0].Power == 0)
 	assert.True(diff[1].Power == 1000)
 }
+
+// the quadratic diff ValidatorsDiff used to compute, kept as a reference
+func validatorsDiffQuadratic(previous, current []*abci.Validator) (diff []*abci.Validator) {
+	for _, prevVal := range previous {
+		if prevVal == nil {
+			continue
+		}
+		found := false
+		for _, curVal := range current {
+			if curVal == nil {
+				continue
+			}
+			if bytes.Equal(prevVal.PubKey, curVal.PubKey) {
+				found = true
+				if curVal.Power != prevVal.Power {
+					diff = append(diff, &abci.Validator{curVal.PubKey, curVal.Power})
+					break
+				}
+			}
+		}
+		if !found {
+			diff = append(diff, &abci.Validator{prevVal.PubKey, 0})
+		}
+	}
+
+	for _, curVal := range current {
+		if curVal == nil {
+			continue
+		}
+		found := false
+		for _, prevVal := range previous {
+			if prevVal != nil && bytes.Equal(prevVal.PubKey, curVal.PubKey) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, &abci.Validator{curVal.PubKey, curVal.Power})
+		}
+	}
+	return
+}
+
+// a random validator set of distinct pubkeys drawn from a pool of poolSize,
+// with a few nil entries
+func randomValidators(r *rand.Rand, n, poolSize int) []*abci.Validator {
+	vals := make([]*abci.Validator, 0, n)
+	for _, i := range r.Perm(poolSize)[:n] {
+		if r.Intn(20) == 0 {
+			vals = append(vals, nil)
+			continue
+		}
+		vals = append(vals, &abci.Validator{
+			PubKey: []byte(fmt.Sprintf("pubkey%d", i)),
+			Power:  uint64(r.Intn(3)),
+		})
+	}
+	return vals
+}
+
+func TestValidatorsDiffEquivalence(t *testing.T) {
+	assert := assert.New(t)
+	store := state.NewMemKVStore()
+	r := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 500; i++ {
+		poolSize := 1 + r.Intn(40)
+		previous := randomValidators(r, r.Intn(poolSize+1), poolSize)
+		current := randomValidators(r, r.Intn(poolSize+1), poolSize)
+
+		expected := validatorsDiffQuadratic(previous, current)
+		diff := ValidatorsDiff(previous, current, store)
+		if len(expected) == 0 {
+			assert.Empty(diff, "previous %v, current %v", previous, current)
+		} else {
+			assert.Equal(expected, diff, "previous %v, current %v", previous, current)
+		}
+
+		// the same sets always give the same diff
+		assert.Equal(diff, ValidatorsDiff(previous, current, store))
+	}
+}
+
+func benchmarkValidatorsDiff(b *testing.B, numVals int, quadratic bool) {
+	store := state.NewMemKVStore()
+	r := rand.New(rand.NewSource(42))
+	previous := randomValidators(r, numVals, 2*numVals)
+	current := randomValidators(r, numVals, 2*numVals)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if quadratic {
+			validatorsDiffQuadratic(previous, current)
+		} else {
+			ValidatorsDiff(previous, current, store)
+		}
+	}
+}
+
+func BenchmarkValidatorsDiff(b *testing.B) {
+	for _, numVals := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("quadratic-%d", numVals), func(b *testing.B) {
+			benchmarkValidatorsDiff(b, numVals, true)
+		})
+		b.Run(fmt.Sprintf("linear-%d", numVals), func(b *testing.B) {
+			benchmarkValidatorsDiff(b, numVals, false)
+		})
+	}
+}
+
+func TestValidatorBondExchangeRate(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	bond := NewValidatorBond(validator, getHoldAccount(validator), []byte("pubkey"))
+
+	// an empty bond exchanges at par
+	assert.Equal(0, bond.ExchangeRate().Cmp(big.NewRat(1, 1)))
+	tokens, err := bond.TokensFromCoins(100, false)
+	require.Nil(err)
+	assert.Equal(uint64(100), tokens)
+
+	// rewards raise the exchange rate, new delegations get fewer tokens
+	bond.BondedTokens, bond.BondedCoins = 100, 150
+	assert.Equal(0, bond.ExchangeRate().Cmp(big.NewRat(3, 2)))
+	assert.Equal(uint64(15), bond.CoinsFromTokens(10))
+	tokens, err = bond.TokensFromCoins(10, false)
+	require.Nil(err)
+	assert.Equal(uint64(6), tokens)
+	tokens, err = bond.TokensFromCoins(10, true)
+	require.Nil(err)
+	assert.Equal(uint64(7), tokens)
+
+	// slashing lowers the exchange rate
+	bond.BondedCoins = 50
+	assert.Equal(0, bond.ExchangeRate().Cmp(big.NewRat(1, 2)))
+	assert.Equal(uint64(5), bond.CoinsFromTokens(10))
+
+	// once all the coins are gone the tokens can't be exchanged
+	bond.BondedCoins = 0
+	_, err = bond.TokensFromCoins(10, false)
+	assert.NotNil(err)
+
+	// voting power follows the coins rather than the tokens
+	bonds := ValidatorBonds{bond}
+	bond.BondedCoins = 150
+	store := state.NewMemKVStore()
+	assert.True(bonds.UpdateVotingPower(store))
+	assert.Equal(uint64(150), bond.VotingPower)
+}
+
+func TestParseFraction(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		input    string
+		expected Fraction
+		wantErr  bool
+	}{
+		{"7/100", NewFraction(7, 100), false},
+		{"0.07", NewFraction(7, 100), false},
+		{"2/4", NewFraction(1, 2), false},
+		{"1", NewFraction(1, 1), false},
+		{"foo", Fraction{}, true},
+		{"1/0", Fraction{}, true},
+	}
+
+	for _, tc := range testCases {
+		f, err := ParseFraction(tc.input)
+		assert.Equal(tc.wantErr, err != nil, "%v", tc.input)
+		if !tc.wantErr {
+			assert.Equal(tc.expected, f, "%v", tc.input)
+		}
+	}
+}
+
+func TestParamsJSON(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	// the field names are relied upon by scripts and genesis files
+	bz, err := json.Marshal(defaultParams())
+	require.Nil(err)
+	var fields map[string]json.RawMessage
+	require.Nil(json.Unmarshal(bz, &fields))
+
+	expected := []string{
+		"max_vals", "allowed_bond_denom", "unbonding_period",
+		"inflation_rate_min", "inflation_rate_max", "goal_bonded",
+		"total_supply", "blocks_per_year", "slash_fraction_double_sign",
+		"signed_blocks_window", "min_signed_per_window", "downtime_jail_period",
+		"slash_fraction_downtime", "epoch_length", "max_power_change_per_block",
+		"gas_bond", "gas_unbond",
+	}
+	assert.Equal(len(expected), len(fields))
+	for _, key := range expected {
+		assert.Contains(fields, key)
+	}
+	assert.Equal(`{"num":7,"denom":100}`, string(fields["inflation_rate_min"]))
+	assert.Equal(`100`, string(fields["max_vals"]))
+	assert.Equal(`"fermion"`, string(fields["allowed_bond_denom"]))
+
+	// each field can be set as a genesis option to its value
+	custom := Params{
+		MaxVals: 7, AllowedBondDenom: "atom", UnbondingPeriod: 8,
+		InflationRateMin: NewFraction(1, 100), InflationRateMax: NewFraction(3, 100),
+		GoalBonded: NewFraction(7, 100), TotalSupply: 9, BlocksPerYear: 10,
+		SlashFractionDoubleSign: NewFraction(9, 100), SignedBlocksWindow: 11,
+		MinSignedPerWindow: NewFraction(11, 100), DowntimeJailPeriod: 12,
+		SlashFractionDowntime: NewFraction(13, 100), EpochLength: 15,
+		MaxPowerChangePerBlock: NewFraction(17, 100), GasBond: 13, GasUnbond: 14,
+	}
+	bz, err = json.Marshal(custom)
+	require.Nil(err)
+	require.Nil(json.Unmarshal(bz, &fields))
+	store := state.NewMemKVStore()
+	for _, key := range expected {
+		var f Fraction
+		value := string(fields[key])
+		if json.Unmarshal(fields[key], &f) == nil && f.Denom != 0 {
+			value = fmt.Sprintf("%v/%v", f.Num, f.Denom)
+		}
+		var str string
+		if json.Unmarshal(fields[key], &str) == nil {
+			value = str
+		}
+		assert.Nil(Handler{}.initState(stakingModuleName, key, value, store), key)
+	}
+	assert.Equal(custom, loadParams(store))
+}
+
+func TestParamsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(defaultParams().Validate())
+
+	testCases := []func(p *Params){
+		func(p *Params) { p.MaxVals = 0 },
+		func(p *Params) { p.AllowedBondDenom = "" },
+		func(p *Params) { p.BlocksPerYear = 0 },
+		func(p *Params) { p.SignedBlocksWindow = 0 },
+		func(p *Params) { p.GoalBonded = NewFraction(3, 2) },
+		func(p *Params) { p.SlashFractionDowntime = Fraction{1, 0} },
+		func(p *Params) { p.MinSignedPerWindow = NewFraction(-1, 2) },
+		func(p *Params) { p.InflationRateMin = NewFraction(1, 2) },
+		func(p *Params) { p.EpochLength = 0 },
+		func(p *Params) { p.MaxPowerChangePerBlock = NewFraction(0, 1) },
+		func(p *Params) { p.MaxPowerChangePerBlock = NewFraction(4, 3) },
+	}
+	for i, tc := range testCases {
+		params := defaultParams()
+		tc(&params)
+		assert.NotNil(params.Validate(), "%d", i)
+	}
+}
+
+func TestSetParam(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	store := state.NewMemKVStore()
+	require.Nil(SetParam(store, "max_vals", "50"))
+	assert.Equal(50, loadParams(store).MaxVals)
+
+	// an invalid value leaves the params unchanged
+	testCases := []struct{ key, value string }{
+		{"max_vals", "many"},
+		{"goal_bonded", "1/0"},
+		{"unknown", "1"},
+		{"unbonding_period", "-1"},
+		{"max_vals", "0"},
+		{"epoch_length", "0"},
+	}
+	for _, tc := range testCases {
+		assert.NotNil(ValidateParam(tc.key, tc.value), tc.key)
+		assert.NotNil(SetParam(store, tc.key, tc.value), tc.key)
+	}
+	params := defaultParams()
+	params.MaxVals = 50
+	assert.Equal(params, loadParams(store))
+	assert.Nil(ValidateParam("unbonding_period", "7"))
+
+	// a value only invalid along the other params passes on its own
+	assert.Nil(ValidateParam("inflation_rate_min", "1/2"))
+	assert.NotNil(SetParam(store, "inflation_rate_min", "1/2"))
+
+	// the changes are checked together, none are set if the result is invalid
+	keys := []string{"inflation_rate_max", "inflation_rate_min"}
+	assert.Nil(ValidateParams(params, keys, []string{"3/5", "1/2"}))
+	assert.NotNil(ValidateParams(params, keys, []string{"1/2", "3/5"}))
+	assert.NotNil(SetParams(store, []string{"max_vals", "epoch_length"}, []string{"7", "0"}))
+	assert.Equal(params, loadParams(store))
+	require.Nil(SetParams(store, keys, []string{"3/5", "1/2"}))
+	assert.Equal(NewFraction(1, 2), loadParams(store).InflationRateMin)
+	assert.NotNil(ValidateParams(params, keys, []string{"1/2"}))
+
+	// the changes are checked against the params they are applied to
+	assert.NotNil(ValidateParams(params, keys[1:], []string{"1/2"}))
+	assert.Nil(ValidateParams(loadParams(store), keys[1:], []string{"1/2"}))
+}